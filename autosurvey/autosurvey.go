@@ -79,7 +79,7 @@ func start(ctx context.Context, name, version string, guid []byte, cs ...ksurvey
 	reg := DefaultRegistry
 	err := reg.Register(ksurveyclient.NewProgramCollector(name, version, autoHashGUID(guid)))
 	if err != nil {
-		return nil
+		return err
 	}
 	for _, c := range cs {
 		err = reg.Register(c)
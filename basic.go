@@ -23,6 +23,12 @@ import (
 	"syscall"
 )
 
+var (
+	machineIDDesc = NewDesc("machine_id", "", StringValue, NoMode)
+	utsnameDesc   = NewDesc("utsname", "Pretty platform name", StringValue, NoMode)
+	osreleaseDesc = NewDesc("osrelease", "Pretty operating system name", StringValue, NoMode)
+)
+
 type basicCollector struct {
 }
 
@@ -31,6 +37,14 @@ func NewBasicCollector() Collector {
 	return &basicCollector{}
 }
 
+// Describe sends the descriptions of the metrics collected by the associated
+// basicCollector.
+func (mc *basicCollector) Describe(ch chan<- *Desc) {
+	ch <- machineIDDesc
+	ch <- utsnameDesc
+	ch <- osreleaseDesc
+}
+
 // Collect first gathers the associated managers collectors managers data. Then
 // it creates constant metrics based on the returned data.
 func (mc *basicCollector) Collect(ch chan<- Metric) {
@@ -43,11 +57,7 @@ func (mc *basicCollector) Collect(ch chan<- Metric) {
 				machineID = scanner.Text()
 			}
 		}
-		ch <- MustNewConstMap("machine_id", map[string]interface{}{
-			"desc":  "",
-			"type":  "string",
-			"value": machineID,
-		})
+		ch <- MustNewConstString(machineIDDesc, machineID)
 	}()
 
 	func() {
@@ -56,11 +66,7 @@ func (mc *basicCollector) Collect(ch chan<- Metric) {
 		if err := syscall.Uname(&buf); err == nil {
 			prettyName = charsToString(buf.Sysname[:]) + " " + charsToString(buf.Machine[:]) + " " + charsToString(buf.Release[:])
 		}
-		ch <- MustNewConstMap("utsname", map[string]interface{}{
-			"desc":  "Pretty platform name",
-			"type":  "string",
-			"value": prettyName,
-		})
+		ch <- MustNewConstString(utsnameDesc, prettyName)
 	}()
 
 	func() {
@@ -85,10 +91,6 @@ func (mc *basicCollector) Collect(ch chan<- Metric) {
 				prettyName = scanner.Text()
 			}
 		}
-		ch <- MustNewConstMap("osrelease", map[string]interface{}{
-			"desc":  "Pretty operating system name",
-			"type":  "string",
-			"value": prettyName,
-		})
+		ch <- MustNewConstString(osreleaseDesc, prettyName)
 	}()
 }
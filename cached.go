@@ -0,0 +1,223 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CachedAgeField is the name of the field which is added to metrics served
+// by a cached Collector when the last refresh of their data failed. Its value
+// is the age of the data in seconds.
+const CachedAgeField = "age"
+
+type cachedCollector struct {
+	collector Collector
+	ttl       time.Duration
+
+	background context.Context
+
+	// refreshLock is a lock which can be given up while waiting for it.
+	refreshLock chan struct{}
+
+	mutex   sync.Mutex
+	content []*MetricData
+	updated time.Time
+	failed  bool
+}
+
+// A CachedCollectorOption configures a cached Collector.
+type CachedCollectorOption func(*cachedCollector)
+
+// WithBackgroundRefresh makes a cached Collector refresh its data every TTL in
+// the background until the provided Context is done. Collecting then never
+// waits for the wrapped Collector, once data was collected successfully. After
+// the Context is done, data is refreshed when collecting again.
+func WithBackgroundRefresh(ctx context.Context) CachedCollectorOption {
+	return func(cc *cachedCollector) {
+		cc.background = ctx
+	}
+}
+
+// NewCachedCollector creates a Collector which collects the Metrics of the
+// provided Collector at most once per provided TTL and serves them from its
+// cache otherwise. When a refresh fails, the previous data is served with the
+// CachedAgeField added. If there is no previous data, the errors of the
+// refresh are reported. Each refresh is given up after the
+// DefaultCollectorTimeout.
+func NewCachedCollector(c Collector, ttl time.Duration, opts ...CachedCollectorOption) Collector {
+	cc := &cachedCollector{
+		collector: c,
+		ttl:       ttl,
+
+		refreshLock: make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(cc)
+	}
+	if cc.background != nil && ttl > 0 {
+		go cc.refreshLoop(cc.background)
+	}
+	return cc
+}
+
+func (cc *cachedCollector) refreshLoop(ctx context.Context) {
+	ticker := time.NewTicker(cc.ttl)
+	defer ticker.Stop()
+	for {
+		cc.refresh(ctx, false)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh collects the Metrics of the wrapped Collector and updates the cache
+// if successful. Concurrent refreshes are serialized, waiting for another
+// refresh is given up when the provided Context is done. If onlyExpired is
+// true, nothing is collected when the cache was updated within the TTL
+// meanwhile.
+func (cc *cachedCollector) refresh(ctx context.Context, onlyExpired bool) *collectedMetrics {
+	select {
+	case cc.refreshLock <- struct{}{}:
+		defer func() {
+			<-cc.refreshLock
+		}()
+	case <-ctx.Done():
+		return &collectedMetrics{
+			errs: MultiError{&CollectorError{
+				Collector: collectorName(cc.collector, 0),
+				Err:       ctx.Err(),
+			}},
+		}
+	}
+
+	if onlyExpired {
+		if _, updated, _ := cc.cached(); !updated.IsZero() && time.Since(updated) < cc.ttl {
+			return &collectedMetrics{}
+		}
+	}
+
+	collected := collect(ctx, cc.collector, 0, DefaultCollectorTimeout)
+
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+	if len(collected.errs) == 0 {
+		cc.content = collected.content
+		cc.updated = time.Now()
+		cc.failed = false
+	} else {
+		cc.failed = true
+	}
+	return collected
+}
+
+func (cc *cachedCollector) cached() ([]*MetricData, time.Time, bool) {
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+	return cc.content, cc.updated, cc.failed
+}
+
+func (cc *cachedCollector) Collect(ch chan<- Metric) {
+	cc.CollectContext(context.Background(), ch)
+}
+
+// CollectContext serves the cached Metrics, refreshing them first if they are
+// older than the TTL and not refreshed in the background.
+func (cc *cachedCollector) CollectContext(ctx context.Context, ch chan<- Metric) {
+	background := cc.background != nil && cc.background.Err() == nil
+	content, updated, failed := cc.cached()
+	if updated.IsZero() || (!background && time.Since(updated) >= cc.ttl) {
+		collected := cc.refresh(ctx, true)
+		content, updated, failed = cc.cached()
+		if updated.IsZero() {
+			// Never succeeded, report what was collected and why it failed.
+			for _, md := range collected.content {
+				ch <- &cachedMetric{md: md}
+			}
+			for _, err := range collected.errs {
+				ch <- &cachedErrorMetric{err: err}
+			}
+			return
+		}
+	}
+
+	var age int64
+	if failed {
+		age = int64(time.Since(updated) / time.Second)
+		if age == 0 {
+			age = 1
+		}
+	}
+	for _, md := range content {
+		ch <- &cachedMetric{
+			md:  md,
+			age: age,
+		}
+	}
+}
+
+// Describe sends the descriptions of the wrapped Collector.
+func (cc *cachedCollector) Describe(ch chan<- *Desc) {
+	if d, ok := cc.collector.(Describer); ok {
+		d.Describe(ch)
+	}
+}
+
+func (cc *cachedCollector) String() string {
+	if stringer, ok := cc.collector.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return fmt.Sprintf("%T", cc.collector)
+}
+
+type cachedMetric struct {
+	md  *MetricData
+	age int64
+}
+
+func (cm *cachedMetric) Write(md *MetricData) error {
+	md.Name = cm.md.Name
+	md.Fields = cm.md.Fields
+	if cm.age > 0 {
+		// NOTE: Cached fields are shared, never modify them.
+		fields := make(map[string]interface{}, len(cm.md.Fields)+1)
+		for key, value := range cm.md.Fields {
+			fields[key] = value
+		}
+		fields[CachedAgeField] = cm.age
+		md.Fields = fields
+	}
+	return nil
+}
+
+type cachedErrorMetric struct {
+	err error
+}
+
+func (cm *cachedErrorMetric) Write(md *MetricData) error {
+	if collectorErr, ok := cm.err.(*CollectorError); ok {
+		md.Name = collectorErr.Metric
+		return collectorErr.Err
+	}
+	return cm.err
+}
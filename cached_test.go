@@ -0,0 +1,212 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countingCollector struct {
+	calls int64
+	fail  int32
+	delay time.Duration
+}
+
+func (c *countingCollector) Collect(ch chan<- Metric) {
+	time.Sleep(c.delay)
+	calls := atomic.AddInt64(&c.calls, 1)
+	if atomic.LoadInt32(&c.fail) != 0 {
+		panic("collector is broken")
+	}
+	ch <- MustNewConstInt(NewDesc("calls", "", IntValue, CounterMode), calls)
+}
+
+func TestCachedCollector(t *testing.T) {
+	inner := &countingCollector{}
+	reg := NewRegistry()
+	reg.MustRegister(NewCachedCollector(inner, time.Hour))
+
+	for i := 0; i < 3; i++ {
+		ms, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("failed to gather: %v", err)
+		}
+		if md := ms.Get("calls"); md == nil || md.Value() != int64(1) {
+			t.Errorf("unexpected metric: %v", md)
+		}
+	}
+	if calls := atomic.LoadInt64(&inner.calls); calls != 1 {
+		t.Errorf("unexpected number of collector calls: %d", calls)
+	}
+}
+
+func TestCachedCollectorStale(t *testing.T) {
+	inner := &countingCollector{}
+	reg := NewRegistry()
+	reg.MustRegister(NewCachedCollector(inner, time.Millisecond))
+
+	if _, err := reg.Gather(); err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	ms, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+	if md := ms.Get("calls"); md.Value() != int64(2) || md.Fields[CachedAgeField] != nil {
+		t.Errorf("unexpected refreshed metric: %v", md.Fields)
+	}
+
+	atomic.StoreInt32(&inner.fail, 1)
+	time.Sleep(5 * time.Millisecond)
+	ms, err = reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+	if md := ms.Get("calls"); md.Value() != int64(2) || md.Fields[CachedAgeField] != int64(1) {
+		t.Errorf("unexpected stale metric: %v", md.Fields)
+	}
+
+	failing := NewRegistry()
+	failing.MustRegister(NewCachedCollector(inner, time.Hour))
+	if _, err := failing.Gather(); err == nil {
+		t.Error("expected error without cached data")
+	}
+}
+
+func TestCachedCollectorBackground(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	inner := &countingCollector{}
+	reg := NewRegistry()
+	reg.MustRegister(NewCachedCollector(inner, 10*time.Millisecond, WithBackgroundRefresh(ctx)))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt64(&inner.calls) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("collector was not refreshed in the background")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	ms, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+	calls := atomic.LoadInt64(&inner.calls)
+	if md := ms.Get("calls"); md == nil || md.Value().(int64) > calls {
+		t.Errorf("unexpected metric: %v", md)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if atomic.LoadInt64(&inner.calls) > calls+1 {
+		t.Error("collector was refreshed after the context was done")
+	}
+}
+
+func TestCachedCollectorBackgroundDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	inner := &countingCollector{}
+	reg := NewRegistry()
+	reg.MustRegister(NewCachedCollector(inner, 10*time.Millisecond, WithBackgroundRefresh(ctx)))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt64(&inner.calls) < 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("collector was not refreshed in the background")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	time.Sleep(30 * time.Millisecond)
+
+	calls := atomic.LoadInt64(&inner.calls)
+	ms, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+	if md := ms.Get("calls"); md == nil || md.Value() != calls+1 || md.Fields[CachedAgeField] != nil {
+		t.Errorf("unexpected metric after background refresh ended: %v", md)
+	}
+
+	atomic.StoreInt32(&inner.fail, 1)
+	time.Sleep(30 * time.Millisecond)
+	ms, err = reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+	if md := ms.Get("calls"); md == nil || md.Value() != calls+1 || md.Fields[CachedAgeField] != int64(1) {
+		t.Errorf("unexpected stale metric after background refresh ended: %v", md)
+	}
+}
+
+func TestCachedCollectorBackgroundSlow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	inner := &countingCollector{delay: 15 * time.Millisecond}
+	reg := NewRegistry()
+	reg.MustRegister(NewCachedCollector(inner, 10*time.Millisecond, WithBackgroundRefresh(ctx)))
+
+	for i := 0; i < 25; i++ {
+		ms, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("failed to gather: %v", err)
+		}
+		if md := ms.Get("calls"); md == nil || md.Fields[CachedAgeField] != nil {
+			t.Fatalf("healthy metric was marked stale: %v", md)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+type hangingCollector struct {
+	release chan struct{}
+}
+
+func (c *hangingCollector) Collect(ch chan<- Metric) {
+	<-c.release
+}
+
+func TestCachedCollectorHanging(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	inner := &hangingCollector{release: make(chan struct{})}
+	defer close(inner.release)
+	reg := NewRegistry(WithCollectorTimeout(10 * time.Millisecond))
+	reg.MustRegister(NewCachedCollector(inner, time.Hour, WithBackgroundRefresh(ctx)))
+
+	time.Sleep(10 * time.Millisecond)
+	goroutines := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		if _, err := reg.Gather(); err == nil {
+			t.Fatal("expected error without cached data")
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if leaked := runtime.NumGoroutine() - goroutines; leaked > 5 {
+		t.Errorf("gathering a hanging collector leaked %d goroutines", leaked)
+	}
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+var (
+	submissionsAttemptedDesc = NewDesc("ksurveyclient_submissions_attempted", "Number of survey submissions attempted", IntValue, CounterMode)
+	submissionsSucceededDesc = NewDesc("ksurveyclient_submissions_succeeded", "Number of survey submissions succeeded", IntValue, CounterMode)
+	submissionsFailedDesc    = NewDesc("ksurveyclient_submissions_failed", "Number of survey submissions failed", IntValue, CounterMode)
+	lastSuccessDesc          = NewDesc("ksurveyclient_last_success_timestamp", "Unix time of the last successful survey submission", IntValue, GaugeMode)
+	payloadBytesDesc         = NewDesc("ksurveyclient_payload_bytes", "Size of the last survey payload in bytes", IntValue, GaugeMode)
+	gatherDurationDesc       = NewDesc("ksurveyclient_gather_duration_seconds", "Duration of the last gather in seconds", FloatValue, GaugeMode)
+)
+
+// ClientMetrics holds counters about the operation of survey clients. It is
+// a Collector, so it can be registered with a Registry to include the
+// survey clients own metrics in the survey payload.
+type ClientMetrics struct {
+	// NOTE: Keep all fields 64-bit aligned for atomic access.
+	submissionsAttempted uint64
+	submissionsSucceeded uint64
+	submissionsFailed    uint64
+	payloadBytes         uint64
+	payloadBytesTotal    uint64
+	lastSuccess          int64
+	gatherDuration       int64
+}
+
+// ClientMetricsSnapshot holds the values of ClientMetrics at a point in time.
+type ClientMetricsSnapshot struct {
+	SubmissionsAttempted uint64
+	SubmissionsSucceeded uint64
+	SubmissionsFailed    uint64
+	PayloadBytes         uint64
+	PayloadBytesTotal    uint64
+	LastSuccess          time.Time
+	GatherDuration       time.Duration
+}
+
+// NewClientMetrics creates new ClientMetrics.
+func NewClientMetrics() *ClientMetrics {
+	return &ClientMetrics{}
+}
+
+// DefaultClientMetrics are the ClientMetrics which are used by survey clients
+// if no other is explicitly specified.
+var DefaultClientMetrics = NewClientMetrics()
+
+func (cm *ClientMetrics) attempted() {
+	atomic.AddUint64(&cm.submissionsAttempted, 1)
+}
+
+func (cm *ClientMetrics) succeeded(when time.Time) {
+	atomic.AddUint64(&cm.submissionsSucceeded, 1)
+	atomic.StoreInt64(&cm.lastSuccess, when.Unix())
+}
+
+func (cm *ClientMetrics) failed() {
+	atomic.AddUint64(&cm.submissionsFailed, 1)
+}
+
+func (cm *ClientMetrics) payload(size int) {
+	atomic.StoreUint64(&cm.payloadBytes, uint64(size))
+	atomic.AddUint64(&cm.payloadBytesTotal, uint64(size))
+}
+
+func (cm *ClientMetrics) gathered(d time.Duration) {
+	atomic.StoreInt64(&cm.gatherDuration, int64(d))
+}
+
+// Snapshot returns the current values of the associated ClientMetrics.
+func (cm *ClientMetrics) Snapshot() *ClientMetricsSnapshot {
+	s := &ClientMetricsSnapshot{
+		SubmissionsAttempted: atomic.LoadUint64(&cm.submissionsAttempted),
+		SubmissionsSucceeded: atomic.LoadUint64(&cm.submissionsSucceeded),
+		SubmissionsFailed:    atomic.LoadUint64(&cm.submissionsFailed),
+		PayloadBytes:         atomic.LoadUint64(&cm.payloadBytes),
+		PayloadBytesTotal:    atomic.LoadUint64(&cm.payloadBytesTotal),
+		GatherDuration:       time.Duration(atomic.LoadInt64(&cm.gatherDuration)),
+	}
+	if lastSuccess := atomic.LoadInt64(&cm.lastSuccess); lastSuccess > 0 {
+		s.LastSuccess = time.Unix(lastSuccess, 0)
+	}
+	return s
+}
+
+// Describe sends the descriptions of the metrics collected by the associated
+// ClientMetrics.
+func (cm *ClientMetrics) Describe(ch chan<- *Desc) {
+	ch <- submissionsAttemptedDesc
+	ch <- submissionsSucceededDesc
+	ch <- submissionsFailedDesc
+	ch <- lastSuccessDesc
+	ch <- payloadBytesDesc
+	ch <- gatherDurationDesc
+}
+
+// Collect creates constant metrics from the current values of the associated
+// ClientMetrics.
+func (cm *ClientMetrics) Collect(ch chan<- Metric) {
+	s := cm.Snapshot()
+
+	ch <- MustNewConstInt(submissionsAttemptedDesc, int64(s.SubmissionsAttempted))
+	ch <- MustNewConstInt(submissionsSucceededDesc, int64(s.SubmissionsSucceeded))
+	ch <- MustNewConstInt(submissionsFailedDesc, int64(s.SubmissionsFailed))
+
+	var lastSuccess int64
+	if !s.LastSuccess.IsZero() {
+		lastSuccess = s.LastSuccess.Unix()
+	}
+	ch <- MustNewConstInt(lastSuccessDesc, lastSuccess)
+	ch <- MustNewConstInt(payloadBytesDesc, int64(s.PayloadBytes))
+	ch <- MustNewConstFloat(gatherDurationDesc, s.GatherDuration.Seconds())
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientMetrics(t *testing.T) {
+	status := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	metrics := NewClientMetrics()
+	ksv := newTestKSurveyClient(ts.Client(), ts.URL)
+	ksv.metrics = metrics
+
+	if err := ksv.Do(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	status = http.StatusInternalServerError
+	if err := ksv.Do(context.Background()); err == nil {
+		t.Fatal("expected error for failed submission")
+	}
+
+	s := metrics.Snapshot()
+	if s.SubmissionsAttempted != 2 {
+		t.Errorf("unexpected attempted count: %v", s.SubmissionsAttempted)
+	}
+	if s.SubmissionsSucceeded != 1 {
+		t.Errorf("unexpected succeeded count: %v", s.SubmissionsSucceeded)
+	}
+	if s.SubmissionsFailed != 1 {
+		t.Errorf("unexpected failed count: %v", s.SubmissionsFailed)
+	}
+	if s.LastSuccess.IsZero() {
+		t.Error("last success is not set")
+	}
+	if s.PayloadBytes == 0 || s.PayloadBytesTotal != 2*s.PayloadBytes {
+		t.Errorf("unexpected payload bytes: %v (total %v)", s.PayloadBytes, s.PayloadBytesTotal)
+	}
+
+	reg := NewRegistry()
+	reg.MustRegister(metrics)
+	ms, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+	if len(ms.Content) != 6 {
+		t.Errorf("unexpected number of client metrics: %d", len(ms.Content))
+	}
+	for _, md := range ms.Content {
+		if md.Name == "ksurveyclient_submissions_failed" && md.Value().(int64) != 1 {
+			t.Errorf("unexpected %s value: %v", md.Name, md.Value())
+		}
+	}
+}
@@ -16,12 +16,84 @@
 
 package ksurveyclient
 
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
 // Collector is the interface implemented by anything that can be used to
 // collect survey Metrics.
 type Collector interface {
 	Collect(ch chan<- Metric)
 }
 
+// CollectorContext is the interface optionally implemented by Collectors which
+// support cancellation. When gathering, CollectContext is called instead of
+// Collect with a Context which is done when the gather is cancelled or the
+// collector timeout is reached.
+type CollectorContext interface {
+	Collector
+	CollectContext(ctx context.Context, ch chan<- Metric)
+}
+
+// Describer is the interface optionally implemented by Collectors which know
+// the Metrics they collect. Describe sends the Desc of each of these Metrics
+// to the provided channel.
+type Describer interface {
+	Describe(ch chan<- *Desc)
+}
+
+// AlreadyRegisteredError is returned when a Collector which is already
+// registered is registered again.
+type AlreadyRegisteredError struct {
+	ExistingCollector Collector
+	NewCollector      Collector
+}
+
+func (err *AlreadyRegisteredError) Error() string {
+	return fmt.Sprintf("collector %T already registered", err.NewCollector)
+}
+
+func describe(d Describer) []*Desc {
+	descChan := make(chan *Desc)
+	go func() {
+		d.Describe(descChan)
+		close(descChan)
+	}()
+
+	descs := make([]*Desc, 0)
+	for desc := range descChan {
+		descs = append(descs, desc)
+	}
+	return descs
+}
+
+// sameCollector reports if the provided Collectors are identical. Collectors
+// of types which cannot be compared are never identical.
+func sameCollector(a, b Collector) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	ta, tb := reflect.TypeOf(a), reflect.TypeOf(b)
+	if ta != tb || !ta.Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// validateCollector checks that the provided Collector can be identified when
+// it is registered or unregistered, which requires a comparable type.
+func validateCollector(c Collector) error {
+	if c == nil {
+		return fmt.Errorf("invalid collector: must not be nil")
+	}
+	if !reflect.TypeOf(c).Comparable() {
+		return fmt.Errorf("invalid collector of type %T: must be comparable, use a pointer", c)
+	}
+	return nil
+}
+
 type selfCollector struct {
 	self Metric
 }
@@ -33,3 +105,10 @@ func (c *selfCollector) init(m Metric) {
 func (c *selfCollector) Collect(ch chan<- Metric) {
 	ch <- c.self
 }
+
+func (c *selfCollector) Describe(ch chan<- *Desc) {
+	md := &MetricData{}
+	if err := c.self.Write(md); err == nil {
+		ch <- md.Desc()
+	}
+}
@@ -17,9 +17,11 @@
 package ksurveyclient
 
 import (
+	"net"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 )
 
 // Config defines the settings for the service client.
@@ -31,8 +33,53 @@ type Config struct {
 	Insecure   bool
 	UserAgent  string
 
-	Logger     logger
+	// Endpoints is an ordered list of submit URLs which, if not empty, is used
+	// instead of URL. Submissions fail over to the next endpoint on connection
+	// errors or server errors. PrimaryRetryInterval is the time in seconds
+	// after which the first endpoint is tried again after a failover, zero
+	// selects the default.
+	Endpoints            []string
+	PrimaryRetryInterval uint64
+
+	// Discovery selects how the submit URL is discovered below the
+	// DiscoveryDomain, one of the Discovery* modes. Discovered URLs are tried
+	// before URL or Endpoints. Resolver is used for SRV lookups and defaults to
+	// net.DefaultResolver.
+	Discovery       string
+	DiscoveryDomain string
+	Resolver        *net.Resolver
+
+	// Timeouts in seconds. ConnectTimeout and TLSHandshakeTimeout only apply
+	// when no HTTPClient is set. RequestTimeout bounds each submission in
+	// total, including gathering. Zero values select the defaults.
+	ConnectTimeout      uint64
+	TLSHandshakeTimeout uint64
+	RequestTimeout      uint64
+
+	// PayloadVersion selects the submitted payload format, PayloadV2 or
+	// PayloadV3. ConsentLevel is reported in the envelope of PayloadV3.
+	// Encoding selects how payloads are encoded, one of the Encoding* values.
+	PayloadVersion int
+	ConsentLevel   string
+	Encoding       string
+
+	// Limits of submitted payloads, zero disables the respective limit.
+	// MaxPayloadSize is the size of the encoded payload in bytes, MaxMetrics
+	// the number of metrics including the payload_truncated marker and
+	// MaxValueLength the length of string values in bytes. See
+	// EssentialMetrics for which metrics are kept with priority.
+	MaxPayloadSize uint64
+	MaxMetrics     uint64
+	MaxValueLength uint64
+
+	// Logger receives formatted log lines, for example from a log.Logger of
+	// the standard library. LeveledLogger, if set, is used instead and receives
+	// structured events with their level.
+	Logger        PrintfLogger
+	LeveledLogger Logger
+
 	HTTPClient *http.Client
+	Metrics    *ClientMetrics
 }
 
 // Clone returns a copy of the associated Config.
@@ -45,10 +92,40 @@ func (c *Config) Clone() *Config {
 		Insecure:   c.Insecure,
 		UserAgent:  c.UserAgent,
 
-		Logger: c.Logger,
+		Endpoints:            append([]string(nil), c.Endpoints...),
+		PrimaryRetryInterval: c.PrimaryRetryInterval,
+
+		Discovery:       c.Discovery,
+		DiscoveryDomain: c.DiscoveryDomain,
+		Resolver:        c.Resolver,
+
+		ConnectTimeout:      c.ConnectTimeout,
+		TLSHandshakeTimeout: c.TLSHandshakeTimeout,
+		RequestTimeout:      c.RequestTimeout,
+
+		PayloadVersion: c.PayloadVersion,
+		ConsentLevel:   c.ConsentLevel,
+		Encoding:       c.Encoding,
+
+		MaxPayloadSize: c.MaxPayloadSize,
+		MaxMetrics:     c.MaxMetrics,
+		MaxValueLength: c.MaxValueLength,
+
+		Logger:        c.Logger,
+		LeveledLogger: c.LeveledLogger,
+
+		Metrics: c.Metrics,
 	}
 }
 
+const (
+	defaultPrimaryRetryInterval = 21600
+
+	defaultConnectTimeout      = 30
+	defaultTLSHandshakeTimeout = 10
+	defaultRequestTimeout      = 60
+)
+
 // DefaultConfig hols the service client default configuration.
 var DefaultConfig = &Config{
 	URL:        "https://stats.kopano.io/api/stats/v1/submit",
@@ -56,13 +133,32 @@ var DefaultConfig = &Config{
 	ErrorDelay: 60,
 	Interval:   3600,
 	Insecure:   false,
-	UserAgent:  "ksurveyclient-go/1.0",
+	UserAgent:  "ksurveyclient-go/" + LibraryVersion,
+
+	PrimaryRetryInterval: defaultPrimaryRetryInterval,
+
+	ConnectTimeout:      defaultConnectTimeout,
+	TLSHandshakeTimeout: defaultTLSHandshakeTimeout,
+	RequestTimeout:      defaultRequestTimeout,
+
+	PayloadVersion: PayloadV2,
+	Encoding:       EncodingJSON,
+
+	MaxPayloadSize: 1048576,
+	MaxMetrics:     1000,
+	MaxValueLength: 4096,
 }
 
 func init() {
 	if v := os.Getenv("KOPANO_SURVEYCLIENT_URL"); v != "" {
 		DefaultConfig.URL = v
 	}
+	if v := os.Getenv("KOPANO_SURVEYCLIENT_ENDPOINTS"); v != "" {
+		DefaultConfig.Endpoints = strings.Fields(v)
+	}
+	if v := os.Getenv("KOPANO_SURVEYCLIENT_PRIMARY_RETRY_INTERVAL"); v != "" {
+		DefaultConfig.PrimaryRetryInterval, _ = strconv.ParseUint(v, 10, 64)
+	}
 	if v := os.Getenv("KOPANO_SURVEYCLIENT_START_DELAY"); v != "" {
 		DefaultConfig.StartDelay, _ = strconv.ParseUint(v, 10, 64)
 	}
@@ -72,6 +168,39 @@ func init() {
 	if v := os.Getenv("KOPANO_SURVEYCLIENT_INTERVAL"); v != "" {
 		DefaultConfig.Interval, _ = strconv.ParseUint(v, 10, 64)
 	}
+	if v := os.Getenv("KOPANO_SURVEYCLIENT_DISCOVERY"); v != "" {
+		DefaultConfig.Discovery = v
+	}
+	if v := os.Getenv("KOPANO_SURVEYCLIENT_DISCOVERY_DOMAIN"); v != "" {
+		DefaultConfig.DiscoveryDomain = v
+	}
+	if v := os.Getenv("KOPANO_SURVEYCLIENT_CONNECT_TIMEOUT"); v != "" {
+		DefaultConfig.ConnectTimeout, _ = strconv.ParseUint(v, 10, 64)
+	}
+	if v := os.Getenv("KOPANO_SURVEYCLIENT_TLS_HANDSHAKE_TIMEOUT"); v != "" {
+		DefaultConfig.TLSHandshakeTimeout, _ = strconv.ParseUint(v, 10, 64)
+	}
+	if v := os.Getenv("KOPANO_SURVEYCLIENT_REQUEST_TIMEOUT"); v != "" {
+		DefaultConfig.RequestTimeout, _ = strconv.ParseUint(v, 10, 64)
+	}
+	if v := os.Getenv("KOPANO_SURVEYCLIENT_PAYLOAD_VERSION"); v != "" {
+		DefaultConfig.PayloadVersion, _ = strconv.Atoi(v)
+	}
+	if v := os.Getenv("KOPANO_SURVEYCLIENT_CONSENT_LEVEL"); v != "" {
+		DefaultConfig.ConsentLevel = v
+	}
+	if v := os.Getenv("KOPANO_SURVEYCLIENT_ENCODING"); v != "" {
+		DefaultConfig.Encoding = v
+	}
+	if v := os.Getenv("KOPANO_SURVEYCLIENT_MAX_PAYLOAD_SIZE"); v != "" {
+		DefaultConfig.MaxPayloadSize, _ = strconv.ParseUint(v, 10, 64)
+	}
+	if v := os.Getenv("KOPANO_SURVEYCLIENT_MAX_METRICS"); v != "" {
+		DefaultConfig.MaxMetrics, _ = strconv.ParseUint(v, 10, 64)
+	}
+	if v := os.Getenv("KOPANO_SURVEYCLIENT_MAX_VALUE_LENGTH"); v != "" {
+		DefaultConfig.MaxValueLength, _ = strconv.ParseUint(v, 10, 64)
+	}
 	if v := os.Getenv("KOPANO_SURVEYCLIENT_INSECURE"); v != "" {
 		DefaultConfig.Insecure = v == "yes"
 	}
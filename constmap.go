@@ -16,6 +16,14 @@
 
 package ksurveyclient
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
 // ConstMap is a used to hold a constant set of key values.
 type ConstMap interface {
 	Metric
@@ -30,8 +38,18 @@ type constMap struct {
 }
 
 // NewConstMap creates a ConstMap with the provided name and field data.
+//
+// The name must start with a letter, underscore or colon, followed by letters,
+// digits, underscores or colons. Label names of vectors follow the same rules
+// but must not contain colons. The fields must contain a "type" which is one of
+// the supported ValueTypes and a "value" matching that type. Float values must
+// be finite. The optional "desc" field must be a string, the optional "mode"
+// field must be one of the supported Modes and all other fields must be
+// encodable as JSON. Prefer the typed constructors like NewConstString.
 func NewConstMap(name string, fields map[string]interface{}) (ConstMap, error) {
-	// TODO(longsleep): Validate name and fields.
+	if err := validateConstMap(name, fields); err != nil {
+		return nil, err
+	}
 	cm := &constMap{
 		name:   name,
 		fields: fields,
@@ -56,3 +74,115 @@ func (mdm *constMap) Write(md *MetricData) error {
 
 	return nil
 }
+
+func validateName(name string) error {
+	return validateIdentifier("metric name", name, true)
+}
+
+func validateLabelName(name string) error {
+	return validateIdentifier("label name", name, false)
+}
+
+func validateIdentifier(kind, name string, colon bool) error {
+	if name == "" {
+		return fmt.Errorf("invalid %s %q: must not be empty", kind, name)
+	}
+	for idx, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+		case r == ':' && colon:
+		case r >= '0' && r <= '9' && idx > 0:
+		default:
+			return fmt.Errorf("invalid %s %q: unexpected character %q at position %d", kind, name, r, idx)
+		}
+	}
+	return nil
+}
+
+func validateConstMap(name string, fields map[string]interface{}) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+
+	mtype, ok := fields["type"]
+	if !ok {
+		return fmt.Errorf("metric %q: missing required field \"type\"", name)
+	}
+	typeName, ok := mtype.(string)
+	if !ok {
+		return fmt.Errorf("metric %q: field \"type\" must be a string, got %T", name, mtype)
+	}
+	value, ok := fields["value"]
+	if !ok {
+		return fmt.Errorf("metric %q: missing required field \"value\"", name)
+	}
+	if err := validateValue(ValueType(typeName), value); err != nil {
+		return fmt.Errorf("metric %q: %v", name, err)
+	}
+
+	for key, v := range fields {
+		switch key {
+		case "type", "value":
+			continue
+		case "desc":
+			if _, ok := v.(string); !ok {
+				return fmt.Errorf("metric %q: field %q must be a string, got %T", name, key, v)
+			}
+		case "mode":
+			mode, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("metric %q: field %q must be a string, got %T", name, key, v)
+			}
+			switch Mode(mode) {
+			case NoMode, GaugeMode, CounterMode, InfoMode:
+			default:
+				return fmt.Errorf("metric %q: unsupported mode %q", name, mode)
+			}
+		default:
+			if _, err := json.Marshal(v); err != nil {
+				return fmt.Errorf("metric %q: field %q cannot be encoded: %v", name, key, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateValue(valueType ValueType, value interface{}) error {
+	if value == nil {
+		return errors.New("value must not be nil")
+	}
+
+	v := reflect.ValueOf(value)
+	switch valueType {
+	case StringValue:
+		if v.Kind() == reflect.String {
+			return nil
+		}
+	case IntValue:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return nil
+		}
+	case FloatValue:
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			if f := v.Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+				return fmt.Errorf("float value %v is not finite", f)
+			}
+			return nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return nil
+		}
+	case BoolValue:
+		if v.Kind() == reflect.Bool {
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported type %q", valueType)
+	}
+
+	return fmt.Errorf("value of kind %s does not match type %q", v.Kind(), valueType)
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewConstMap(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		fields map[string]interface{}
+		ok     bool
+	}{
+		{"usercnt_active", map[string]interface{}{"type": "int", "value": 42}, true},
+		{"_private1", map[string]interface{}{"type": "int", "value": uint64(1)}, true},
+		{"osrelease", map[string]interface{}{"desc": "", "type": "string", "value": "Linux"}, true},
+		{"ratio", map[string]interface{}{"type": "float", "mode": "gauge", "value": 0.5}, true},
+		{"ratio", map[string]interface{}{"type": "float", "value": 1}, true},
+		{"enabled", map[string]interface{}{"type": "bool", "value": true}, true},
+		{"extra", map[string]interface{}{"type": "int", "value": 1, "unit": "users"}, true},
+		{"", map[string]interface{}{"type": "int", "value": 1}, false},
+		{"1st", map[string]interface{}{"type": "int", "value": 1}, false},
+		{"user-count", map[string]interface{}{"type": "int", "value": 1}, false},
+		{"job:usercnt_active:max", map[string]interface{}{"type": "int", "value": 1}, true},
+		{"notype", map[string]interface{}{"value": 1}, false},
+		{"novalue", map[string]interface{}{"type": "int"}, false},
+		{"badtype", map[string]interface{}{"type": 1, "value": 1}, false},
+		{"unknowntype", map[string]interface{}{"type": "complex", "value": 1}, false},
+		{"mismatch", map[string]interface{}{"type": "int", "value": "1"}, false},
+		{"mismatch", map[string]interface{}{"type": "int", "value": 1.5}, false},
+		{"mismatch", map[string]interface{}{"type": "string", "value": 1}, false},
+		{"mismatch", map[string]interface{}{"type": "bool", "value": "true"}, false},
+		{"nilvalue", map[string]interface{}{"type": "string", "value": nil}, false},
+		{"nan", map[string]interface{}{"type": "float", "value": math.NaN()}, false},
+		{"inf", map[string]interface{}{"type": "float", "value": math.Inf(1)}, false},
+		{"chan", map[string]interface{}{"type": "int", "value": make(chan int)}, false},
+		{"func", map[string]interface{}{"type": "int", "value": 1, "extra": func() {}}, false},
+		{"desc", map[string]interface{}{"type": "int", "value": 1, "desc": 1}, false},
+	} {
+		_, err := NewConstMap(tc.name, tc.fields)
+		if tc.ok && err != nil {
+			t.Errorf("unexpected error for %q %v: %v", tc.name, tc.fields, err)
+		}
+		if !tc.ok && err == nil {
+			t.Errorf("expected error for %q %v", tc.name, tc.fields)
+		}
+	}
+}
+
+func TestMustNewConstMapPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for invalid ConstMap")
+		}
+	}()
+	MustNewConstMap("", nil)
+}
@@ -0,0 +1,183 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package counter
+
+import (
+	"fmt"
+	"math"
+
+	"stash.kopano.io/kgol/ksurveyclient-go"
+)
+
+// SurveyUint is a Uint which is also a ksurveyclient.Metric and
+// ksurveyclient.Collector, so it can be registered with a
+// ksurveyclient.Registry directly.
+type SurveyUint interface {
+	Uint
+	ksurveyclient.Metric
+	ksurveyclient.Collector
+}
+
+// SurveyUintMinMax is a UintMinMax which is also a ksurveyclient.Metric and
+// ksurveyclient.Collector.
+type SurveyUintMinMax interface {
+	UintMinMax
+	ksurveyclient.Metric
+	ksurveyclient.Collector
+}
+
+type surveyMetric struct {
+	desc *ksurveyclient.Desc
+}
+
+func newSurveyMetric(name, help string) (surveyMetric, error) {
+	desc := ksurveyclient.NewDesc(name, help, ksurveyclient.IntValue, ksurveyclient.GaugeMode)
+	// Validate the Desc with a value which is always in range.
+	if _, err := ksurveyclient.NewConstInt(desc, 0); err != nil {
+		return surveyMetric{}, err
+	}
+	return surveyMetric{
+		desc: desc,
+	}, nil
+}
+
+func (m *surveyMetric) write(md *ksurveyclient.MetricData, value uint64) error {
+	if value > math.MaxInt64 {
+		return fmt.Errorf("metric %q: value %d out of range", m.desc.Name, value)
+	}
+	cm, err := ksurveyclient.NewConstInt(m.desc, int64(value))
+	if err != nil {
+		return err
+	}
+	return cm.Write(md)
+}
+
+// Describe sends the Desc of the associated counter.
+func (m *surveyMetric) Describe(ch chan<- *ksurveyclient.Desc) {
+	ch <- m.desc
+}
+
+type surveyUint struct {
+	uintImpl
+	surveyMetric
+}
+
+// GetSurveyUint returns a new counter to count positive integers, which is
+// collected with the provided name and description as int gauge, since it can
+// be decremented and set. It fails if the name is invalid.
+func GetSurveyUint(name, help string) (SurveyUint, error) {
+	m, err := newSurveyMetric(name, help)
+	if err != nil {
+		return nil, err
+	}
+	return &surveyUint{
+		surveyMetric: m,
+	}, nil
+}
+
+// MustGetSurveyUint is like GetSurveyUint but panics if an error occurs.
+func MustGetSurveyUint(name, help string) SurveyUint {
+	c, err := GetSurveyUint(name, help)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func (c *surveyUint) Write(md *ksurveyclient.MetricData) error {
+	return c.write(md, c.Value())
+}
+
+func (c *surveyUint) Collect(ch chan<- ksurveyclient.Metric) {
+	ch <- c
+}
+
+type surveyUintMax struct {
+	uintMaxImpl
+	surveyMetric
+}
+
+// GetSurveyUintMax returns a new counter to count maximum positive integers,
+// which is collected with the provided name and description as int gauge. It
+// fails if the name is invalid.
+func GetSurveyUintMax(name, help string) (SurveyUintMinMax, error) {
+	m, err := newSurveyMetric(name, help)
+	if err != nil {
+		return nil, err
+	}
+	return &surveyUintMax{
+		surveyMetric: m,
+	}, nil
+}
+
+// MustGetSurveyUintMax is like GetSurveyUintMax but panics if an error occurs.
+func MustGetSurveyUintMax(name, help string) SurveyUintMinMax {
+	c, err := GetSurveyUintMax(name, help)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func (c *surveyUintMax) Write(md *ksurveyclient.MetricData) error {
+	return c.write(md, c.Value())
+}
+
+func (c *surveyUintMax) Collect(ch chan<- ksurveyclient.Metric) {
+	ch <- c
+}
+
+type surveyUintMin struct {
+	uintMinImpl
+	surveyMetric
+}
+
+// GetSurveyUintMin returns a new counter to count minimum positive integers,
+// which is collected with the provided name and description as int gauge.
+// Nothing is collected until a value was set. It fails if the name is invalid.
+func GetSurveyUintMin(name, help string) (SurveyUintMinMax, error) {
+	m, err := newSurveyMetric(name, help)
+	if err != nil {
+		return nil, err
+	}
+	return &surveyUintMin{
+		uintMinImpl:  uintMinImpl{value: maxUint64},
+		surveyMetric: m,
+	}, nil
+}
+
+// MustGetSurveyUintMin is like GetSurveyUintMin but panics if an error occurs.
+func MustGetSurveyUintMin(name, help string) SurveyUintMinMax {
+	c, err := GetSurveyUintMin(name, help)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func (c *surveyUintMin) Write(md *ksurveyclient.MetricData) error {
+	value := c.Value()
+	if value == maxUint64 {
+		// No value set yet, write nothing.
+		return nil
+	}
+	return c.write(md, value)
+}
+
+func (c *surveyUintMin) Collect(ch chan<- ksurveyclient.Metric) {
+	ch <- c
+}
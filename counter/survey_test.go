@@ -0,0 +1,89 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package counter
+
+import (
+	"testing"
+
+	"stash.kopano.io/kgol/ksurveyclient-go"
+)
+
+func TestSurveyCounters(t *testing.T) {
+	requests := MustGetSurveyUint("requests", "Number of requests")
+	connectionsMax := MustGetSurveyUintMax("connections_max", "Maximum number of connections")
+	connectionsMin := MustGetSurveyUintMin("connections_min", "Minimum number of connections")
+
+	reg := ksurveyclient.NewRegistry()
+	reg.MustRegister(requests, connectionsMax, connectionsMin)
+	if err := reg.Register(MustGetSurveyUint("requests", "")); err == nil {
+		t.Error("expected error for duplicate name")
+	}
+
+	ms, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+	if md := ms.Get("connections_min"); md != nil {
+		t.Errorf("unset min counter was collected: %v", md.Fields)
+	}
+
+	requests.Add(3)
+	requests.Inc()
+	connectionsMax.Set(10)
+	connectionsMax.Set(5)
+	connectionsMin.Set(10)
+	connectionsMin.Set(5)
+
+	ms, err = reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+	for _, expected := range []struct {
+		name  string
+		value int64
+		mode  ksurveyclient.Mode
+	}{
+		{"requests", 4, ksurveyclient.GaugeMode},
+		{"connections_max", 10, ksurveyclient.GaugeMode},
+		{"connections_min", 5, ksurveyclient.GaugeMode},
+	} {
+		md := ms.Get(expected.name)
+		if md == nil || md.Value() != expected.value || md.ValueType() != ksurveyclient.IntValue || md.Mode() != expected.mode {
+			t.Errorf("unexpected metric %s: %v", expected.name, md)
+		}
+	}
+	if err := ksurveyclient.Validate(&ksurveyclient.Payload{Version: ksurveyclient.PayloadV2, Stats: ms}); err != nil {
+		t.Errorf("invalid payload: %v", err)
+	}
+
+	requests.Set(maxUint64)
+	if _, err := reg.Gather(); err == nil {
+		t.Error("expected error for value out of range")
+	}
+}
+
+func TestSurveyCountersInvalidName(t *testing.T) {
+	if _, err := GetSurveyUint("bad-name", ""); err == nil {
+		t.Error("expected error for invalid name")
+	}
+	if _, err := GetSurveyUintMax("", ""); err == nil {
+		t.Error("expected error for empty name")
+	}
+	if _, err := GetSurveyUintMin("1st", ""); err == nil {
+		t.Error("expected error for invalid name")
+	}
+}
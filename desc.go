@@ -0,0 +1,135 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"fmt"
+)
+
+// ValueType is the type of the value of a Metric.
+type ValueType string
+
+// Supported ValueTypes.
+const (
+	StringValue ValueType = "string"
+	IntValue    ValueType = "int"
+	FloatValue  ValueType = "float"
+	BoolValue   ValueType = "bool"
+)
+
+// Mode defines how the value of a Metric behaves over time.
+type Mode string
+
+// Supported Modes. NoMode leaves the Mode unspecified.
+const (
+	NoMode      Mode = ""
+	GaugeMode   Mode = "gauge"
+	CounterMode Mode = "counter"
+	InfoMode    Mode = "info"
+)
+
+// Desc describes a Metric with its name, help text, value type and mode.
+type Desc struct {
+	Name      string
+	Help      string
+	ValueType ValueType
+	Mode      Mode
+}
+
+// NewDesc creates a new Desc with the provided values.
+func NewDesc(name, help string, valueType ValueType, mode Mode) *Desc {
+	return &Desc{
+		Name:      name,
+		Help:      help,
+		ValueType: valueType,
+		Mode:      mode,
+	}
+}
+
+// fields returns the wire representation of the associated Desc with the
+// provided value.
+func (d *Desc) fields(valueType ValueType, value interface{}) (map[string]interface{}, error) {
+	if d.ValueType != "" && d.ValueType != valueType {
+		return nil, fmt.Errorf("metric %q: declared type %q does not match value type %q", d.Name, d.ValueType, valueType)
+	}
+
+	fields := map[string]interface{}{
+		"desc":  d.Help,
+		"type":  string(valueType),
+		"value": value,
+	}
+	if d.Mode != NoMode {
+		fields["mode"] = string(d.Mode)
+	}
+	return fields, nil
+}
+
+func newConst(desc *Desc, valueType ValueType, value interface{}) (ConstMap, error) {
+	fields, err := desc.fields(valueType, value)
+	if err != nil {
+		return nil, err
+	}
+	return NewConstMap(desc.Name, fields)
+}
+
+func mustNewConst(desc *Desc, valueType ValueType, value interface{}) ConstMap {
+	cm, err := newConst(desc, valueType, value)
+	if err != nil {
+		panic(err)
+	}
+	return cm
+}
+
+// NewConstString creates a ConstMap with the provided Desc and string value.
+func NewConstString(desc *Desc, value string) (ConstMap, error) {
+	return newConst(desc, StringValue, value)
+}
+
+// MustNewConstString is like NewConstString but panics if an error occurs.
+func MustNewConstString(desc *Desc, value string) ConstMap {
+	return mustNewConst(desc, StringValue, value)
+}
+
+// NewConstInt creates a ConstMap with the provided Desc and integer value.
+func NewConstInt(desc *Desc, value int64) (ConstMap, error) {
+	return newConst(desc, IntValue, value)
+}
+
+// MustNewConstInt is like NewConstInt but panics if an error occurs.
+func MustNewConstInt(desc *Desc, value int64) ConstMap {
+	return mustNewConst(desc, IntValue, value)
+}
+
+// NewConstFloat creates a ConstMap with the provided Desc and float value.
+func NewConstFloat(desc *Desc, value float64) (ConstMap, error) {
+	return newConst(desc, FloatValue, value)
+}
+
+// MustNewConstFloat is like NewConstFloat but panics if an error occurs.
+func MustNewConstFloat(desc *Desc, value float64) ConstMap {
+	return mustNewConst(desc, FloatValue, value)
+}
+
+// NewConstBool creates a ConstMap with the provided Desc and bool value.
+func NewConstBool(desc *Desc, value bool) (ConstMap, error) {
+	return newConst(desc, BoolValue, value)
+}
+
+// MustNewConstBool is like NewConstBool but panics if an error occurs.
+func MustNewConstBool(desc *Desc, value bool) ConstMap {
+	return mustNewConst(desc, BoolValue, value)
+}
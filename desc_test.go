@@ -0,0 +1,73 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTypedConstWireCompatibility(t *testing.T) {
+	typed := MustNewConstInt(NewDesc("usercnt_active", "Active users", IntValue, GaugeMode), 42)
+	legacy := MustNewConstMap("usercnt_active", map[string]interface{}{
+		"desc":  "Active users",
+		"type":  "int",
+		"mode":  "gauge",
+		"value": int64(42),
+	})
+
+	encode := func(m Metric) string {
+		md := &MetricData{}
+		if err := m.Write(md); err != nil {
+			t.Fatalf("failed to write metric: %v", err)
+		}
+		b, err := json.Marshal(&MetricSet{Content: []*MetricData{md}})
+		if err != nil {
+			t.Fatalf("failed to encode metric: %v", err)
+		}
+		return string(b)
+	}
+	if typedJSON, legacyJSON := encode(typed), encode(legacy); typedJSON != legacyJSON {
+		t.Errorf("typed wire format %s differs from legacy %s", typedJSON, legacyJSON)
+	}
+}
+
+func TestTypedConstDesc(t *testing.T) {
+	desc := NewDesc("ratio", "Some ratio", FloatValue, GaugeMode)
+	m := MustNewConstFloat(desc, 0.25)
+	md := &MetricData{}
+	m.Write(md)
+	if md.Help() != "Some ratio" || md.ValueType() != FloatValue || md.Mode() != GaugeMode {
+		t.Errorf("unexpected metric data: %v", md.Fields)
+	}
+	if v, ok := md.Value().(float64); !ok || v != 0.25 {
+		t.Errorf("unexpected value: %v", md.Value())
+	}
+	if *md.Desc() != *desc {
+		t.Errorf("unexpected desc: %v", md.Desc())
+	}
+
+	if _, err := NewConstString(desc, "0.25"); err == nil {
+		t.Error("expected error for mismatching value type")
+	}
+	if _, err := NewConstBool(NewDesc("enabled", "", "", InfoMode), true); err != nil {
+		t.Errorf("unexpected error for desc without value type: %v", err)
+	}
+	if _, err := NewConstInt(NewDesc("count", "", IntValue, Mode("histogram")), 1); err == nil {
+		t.Error("expected error for unsupported mode")
+	}
+}
@@ -0,0 +1,219 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Supported endpoint discovery modes.
+const (
+	DiscoveryNone      = ""
+	DiscoverySRV       = "srv"
+	DiscoveryWellKnown = "well-known"
+	DiscoveryAuto      = "auto"
+)
+
+// Names used for endpoint discovery.
+const (
+	DiscoverySRVService   = "ksurvey"
+	DiscoveryWellKnownURI = "/.well-known/ksurvey"
+)
+
+// A WellKnownDocument is the document served at DiscoveryWellKnownURI below
+// the discovery domain.
+type WellKnownDocument struct {
+	SubmitURL string `json:"submit_url"`
+}
+
+type srvResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+type discoverer struct {
+	mode   string
+	domain string
+	path   string
+
+	resolver srvResolver
+	client   *http.Client
+
+	fallback     []string
+	primaryRetry time.Duration
+
+	mutex      sync.Mutex
+	discovered string
+	endpoints  *endpointList
+}
+
+func newDiscoverer(mode, domain, path string, resolver srvResolver, client *http.Client, fallback []*url.URL, primaryRetry time.Duration) (*discoverer, error) {
+	switch mode {
+	case DiscoverySRV, DiscoveryWellKnown, DiscoveryAuto:
+	default:
+		return nil, fmt.Errorf("invalid discovery mode: %v", mode)
+	}
+	if domain == "" {
+		return nil, errors.New("discovery requires a domain")
+	}
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	fallbackURLs := make([]string, len(fallback))
+	for idx, u := range fallback {
+		fallbackURLs[idx] = u.String()
+	}
+
+	return &discoverer{
+		mode:   mode,
+		domain: domain,
+		path:   path,
+
+		resolver: resolver,
+		client:   client,
+
+		fallback:     fallbackURLs,
+		primaryRetry: primaryRetry,
+	}, nil
+}
+
+// discover returns the endpointList found via the associated discoverer's
+// mode, followed by the fallback URLs. The returned endpointList stays the same
+// as long as the discovered URLs do not change.
+func (d *discoverer) discover(ctx context.Context) (*endpointList, error) {
+	var rawURLs []string
+	var err error
+
+	switch d.mode {
+	case DiscoverySRV:
+		rawURLs, err = d.lookupSRV(ctx)
+	case DiscoveryWellKnown:
+		rawURLs, err = d.fetchWellKnown(ctx)
+	case DiscoveryAuto:
+		rawURLs, err = d.lookupSRV(ctx)
+		if err != nil {
+			var wellKnownErr error
+			rawURLs, wellKnownErr = d.fetchWellKnown(ctx)
+			if wellKnownErr != nil {
+				err = fmt.Errorf("%v, %v", err, wellKnownErr)
+			} else {
+				err = nil
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, rawURL := range d.fallback {
+		found := false
+		for _, discoveredURL := range rawURLs {
+			if discoveredURL == rawURL {
+				found = true
+				break
+			}
+		}
+		if !found {
+			rawURLs = append(rawURLs, rawURL)
+		}
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	discovered := strings.Join(rawURLs, " ")
+	if d.endpoints == nil || discovered != d.discovered {
+		urls, err := parseEndpoints(rawURLs...)
+		if err != nil {
+			return nil, err
+		}
+		d.endpoints = newEndpointList(urls, d.primaryRetry)
+		d.discovered = discovered
+	}
+
+	return d.endpoints, nil
+}
+
+func (d *discoverer) lookupSRV(ctx context.Context) ([]string, error) {
+	_, records, err := d.resolver.LookupSRV(ctx, DiscoverySRVService, "tcp", d.domain)
+	if err != nil {
+		return nil, fmt.Errorf("srv discovery failed: %v", err)
+	}
+
+	rawURLs := make([]string, 0, len(records))
+	for _, record := range records {
+		target := strings.TrimSuffix(record.Target, ".")
+		if target == "" {
+			// Service explicitly not available.
+			continue
+		}
+		u := &url.URL{
+			Scheme: "https",
+			Host:   net.JoinHostPort(target, strconv.Itoa(int(record.Port))),
+			Path:   d.path,
+		}
+		rawURLs = append(rawURLs, u.String())
+	}
+	if len(rawURLs) == 0 {
+		return nil, errors.New("srv discovery failed: no usable records")
+	}
+
+	return rawURLs, nil
+}
+
+func (d *discoverer) fetchWellKnown(ctx context.Context) ([]string, error) {
+	u := &url.URL{
+		Scheme: "https",
+		Host:   d.domain,
+		Path:   DiscoveryWellKnownURI,
+	}
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("well-known discovery failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("well-known discovery failed: unexpected response status: %d", resp.StatusCode)
+	}
+
+	doc := &WellKnownDocument{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(doc); err != nil {
+		return nil, fmt.Errorf("well-known discovery failed: %v", err)
+	}
+	if doc.SubmitURL == "" {
+		return nil, errors.New("well-known discovery failed: no submit_url")
+	}
+
+	return []string{doc.SubmitURL}, nil
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type testingResolver struct {
+	records map[string][]*net.SRV
+}
+
+func (r *testingResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	cname := "_" + service + "._" + proto + "." + name + "."
+	records, ok := r.records[cname]
+	if !ok {
+		return "", nil, errors.New("no such host")
+	}
+	return cname, records, nil
+}
+
+func TestDiscoverySRV(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/stats/v1/submit" {
+			t.Errorf("unexpected path: %v", req.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	tsURL, _ := url.Parse(ts.URL)
+	port, _ := strconv.Atoi(tsURL.Port())
+	resolver := &testingResolver{
+		records: map[string][]*net.SRV{
+			"_ksurvey._tcp.example.com.": {
+				{Target: tsURL.Hostname() + ".", Port: uint16(port)},
+			},
+		},
+	}
+
+	fallback, _ := parseEndpoints(DefaultConfig.URL)
+	d, err := newDiscoverer(DiscoverySRV, "example.com", "/api/stats/v1/submit", resolver, ts.Client(), fallback, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create discoverer: %v", err)
+	}
+	endpoints, err := d.discover(context.Background())
+	if err != nil {
+		t.Fatalf("discovery failed: %v", err)
+	}
+	candidates := endpoints.candidates(time.Now())
+	if len(candidates) != 2 {
+		t.Fatalf("unexpected number of endpoints: %v", candidates)
+	}
+	if candidates[0].String() != ts.URL+"/api/stats/v1/submit" {
+		t.Errorf("unexpected discovered endpoint: %v", candidates[0])
+	}
+	if candidates[1].String() != DefaultConfig.URL {
+		t.Errorf("unexpected fallback endpoint: %v", candidates[1])
+	}
+
+	again, _ := d.discover(context.Background())
+	if again != endpoints {
+		t.Error("endpoints changed without discovery change")
+	}
+
+	ksv := newTestKSurveyClient(ts.Client(), "https://invalid.example.com")
+	ksv.discoverer = d
+	if err := ksv.Do(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	d.domain = "unknown.example.com"
+	if _, err := d.discover(context.Background()); err == nil {
+		t.Error("expected discovery error for unknown domain")
+	}
+}
+
+func TestDiscoveryWellKnown(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case DiscoveryWellKnownURI:
+			json.NewEncoder(rw).Encode(&WellKnownDocument{
+				SubmitURL: ts.URL + "/submit",
+			})
+		case "/submit":
+		default:
+			http.NotFound(rw, req)
+		}
+	}))
+	defer ts.Close()
+
+	tsURL, _ := url.Parse(ts.URL)
+	d, err := newDiscoverer(DiscoveryAuto, tsURL.Host, "", &testingResolver{}, ts.Client(), nil, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create discoverer: %v", err)
+	}
+	endpoints, err := d.discover(context.Background())
+	if err != nil {
+		t.Fatalf("discovery failed: %v", err)
+	}
+	candidates := endpoints.candidates(time.Now())
+	if len(candidates) != 1 || candidates[0].String() != ts.URL+"/submit" {
+		t.Errorf("unexpected discovered endpoints: %v", candidates)
+	}
+
+	if _, err := newDiscoverer("dns", "example.com", "", nil, nil, nil, 0); err == nil {
+		t.Error("expected error for invalid discovery mode")
+	}
+}
@@ -0,0 +1,215 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// DuplicatePolicy defines how multiple Metrics with the same name are handled
+// when gathering.
+type DuplicatePolicy int
+
+// Supported DuplicatePolicies.
+const (
+	// DuplicateReport keeps the Metric of the first registered Collector and
+	// reports each duplicate with a DuplicateMetricError.
+	DuplicateReport DuplicatePolicy = iota
+	// DuplicateError fails the gather with a DuplicateMetricError.
+	DuplicateError
+	// DuplicateFirst keeps the Metric of the first registered Collector.
+	DuplicateFirst
+	// DuplicateSum adds up the values of numeric Metrics.
+	DuplicateSum
+	// DuplicateMax keeps the largest value of numeric Metrics.
+	DuplicateMax
+)
+
+// String returns the name of the associated DuplicatePolicy.
+func (policy DuplicatePolicy) String() string {
+	switch policy {
+	case DuplicateReport:
+		return "report"
+	case DuplicateError:
+		return "error"
+	case DuplicateFirst:
+		return "first"
+	case DuplicateSum:
+		return "sum"
+	case DuplicateMax:
+		return "max"
+	default:
+		return fmt.Sprintf("DuplicatePolicy(%d)", int(policy))
+	}
+}
+
+// A DuplicateMetricError is returned when multiple Collectors collected a
+// Metric with the same name.
+type DuplicateMetricError struct {
+	Name       string
+	Collectors []string
+}
+
+func (err *DuplicateMetricError) Error() string {
+	return fmt.Sprintf("duplicate metric %q collected by %s", err.Name, strings.Join(err.Collectors, ", "))
+}
+
+// A MultiError holds multiple errors.
+type MultiError []error
+
+func (errs MultiError) Error() string {
+	msgs := make([]string, len(errs))
+	for idx, err := range errs {
+		msgs[idx] = err.Error()
+	}
+	return fmt.Sprintf("%d error(s) occurred: %s", len(errs), strings.Join(msgs, "; "))
+}
+
+// MaybeUnwrap returns nil if the associated MultiError is empty, the only
+// error if it holds exactly one error and itself otherwise.
+func (errs MultiError) MaybeUnwrap() error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return errs
+	}
+}
+
+// mergeCollectedMetrics flattens the provided collected metrics in order,
+// applying the provided DuplicatePolicy to metrics with the same name. The
+// returned errors report duplicates which were not merged, in which case the
+// metric collected first is kept.
+func mergeCollectedMetrics(collected []*collectedMetrics, policy DuplicatePolicy) ([]*MetricData, MultiError) {
+	content := make([]*MetricData, 0)
+	seen := make(map[string]int)
+	duplicates := make(map[string]*DuplicateMetricError)
+	var errs MultiError
+
+	for _, cm := range collected {
+		if cm == nil {
+			continue
+		}
+		for _, md := range cm.content {
+			idx, exists := seen[md.Name]
+			if !exists {
+				seen[md.Name] = len(content)
+				content = append(content, md)
+				duplicates[md.Name] = &DuplicateMetricError{
+					Name:       md.Name,
+					Collectors: []string{cm.collector},
+				}
+				continue
+			}
+
+			dup := duplicates[md.Name]
+			dup.Collectors = append(dup.Collectors, cm.collector)
+			switch policy {
+			case DuplicateFirst:
+			case DuplicateSum, DuplicateMax:
+				merged, err := mergeMetricData(content[idx], md, policy)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				content[idx] = merged
+			default:
+				if len(dup.Collectors) == 2 {
+					errs = append(errs, dup)
+				}
+			}
+		}
+	}
+
+	return content, errs
+}
+
+func mergeMetricData(a, b *MetricData, policy DuplicatePolicy) (*MetricData, error) {
+	if a.ValueType() != b.ValueType() {
+		return nil, fmt.Errorf("duplicate metric %q cannot be merged: type %q differs from %q", a.Name, a.ValueType(), b.ValueType())
+	}
+
+	var value interface{}
+	switch a.ValueType() {
+	case IntValue:
+		av, aok := toInt64(a.Value())
+		bv, bok := toInt64(b.Value())
+		if !aok || !bok {
+			return nil, fmt.Errorf("duplicate metric %q cannot be merged: invalid int values", a.Name)
+		}
+		if policy == DuplicateSum {
+			value = av + bv
+		} else if bv > av {
+			value = bv
+		} else {
+			value = av
+		}
+	case FloatValue:
+		av, aok := toFloat64(a.Value())
+		bv, bok := toFloat64(b.Value())
+		if !aok || !bok {
+			return nil, fmt.Errorf("duplicate metric %q cannot be merged: invalid float values", a.Name)
+		}
+		if policy == DuplicateSum {
+			value = av + bv
+		} else if bv > av {
+			value = bv
+		} else {
+			value = av
+		}
+	default:
+		return nil, fmt.Errorf("duplicate metric %q cannot be merged: type %q is not numeric", a.Name, a.ValueType())
+	}
+
+	fields := make(map[string]interface{}, len(a.Fields))
+	for k, v := range a.Fields {
+		fields[k] = v
+	}
+	fields["value"] = value
+
+	return &MetricData{
+		Name:   a.Name,
+		Fields: fields,
+	}, nil
+}
+
+func toInt64(value interface{}) (int64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint()), true
+	}
+	return 0, false
+}
+
+func toFloat64(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	if i, ok := toInt64(value); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+
+	"github.com/fxamacker/cbor"
+)
+
+// Supported payload encodings.
+const (
+	EncodingJSON = "json"
+	EncodingCBOR = "cbor"
+)
+
+// Content types of the supported payload encodings.
+const (
+	ContentTypeJSON = "application/json"
+	ContentTypeCBOR = "application/cbor"
+)
+
+// An Encoder encodes Payloads for submission.
+type Encoder interface {
+	// ContentType returns the value of the Content-Type header which is sent
+	// with encoded Payloads.
+	ContentType() string
+	Encode(w io.Writer, payload *Payload) error
+}
+
+// A Decoder decodes submitted Payloads.
+type Decoder interface {
+	ContentType() string
+	Decode(r io.Reader) (*Payload, error)
+}
+
+// NewEncoder returns the Encoder for the provided encoding, one of the
+// Encoding* values. An empty encoding selects EncodingJSON.
+func NewEncoder(encoding string) (Encoder, error) {
+	switch encoding {
+	case "", EncodingJSON:
+		return &jsonEncoding{}, nil
+	case EncodingCBOR:
+		return &cborEncoding{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %v", encoding)
+	}
+}
+
+// NewDecoder returns the Decoder for the provided Content-Type header value.
+// An empty contentType selects JSON.
+func NewDecoder(contentType string) (Decoder, error) {
+	if contentType == "" {
+		return &jsonEncoding{}, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, err
+	}
+	switch mediaType {
+	case ContentTypeJSON:
+		return &jsonEncoding{}, nil
+	case ContentTypeCBOR:
+		return &cborEncoding{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported content type: %v", mediaType)
+	}
+}
+
+type jsonEncoding struct{}
+
+func (e *jsonEncoding) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (e *jsonEncoding) Encode(w io.Writer, payload *Payload) error {
+	return EncodePayload(w, payload)
+}
+
+func (e *jsonEncoding) Decode(r io.Reader) (*Payload, error) {
+	return DecodePayload(r)
+}
+
+type cborEncoding struct{}
+
+func (e *cborEncoding) ContentType() string {
+	return ContentTypeCBOR
+}
+
+func (e *cborEncoding) Encode(w io.Writer, payload *Payload) error {
+	payload, err := checkPayload(payload)
+	if err != nil {
+		return err
+	}
+
+	return cbor.NewEncoder(w, cbor.CoreDetEncOptions()).Encode(payload)
+}
+
+func (e *cborEncoding) Decode(r io.Reader) (*Payload, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var header struct {
+		Version *int `cbor:"version"`
+	}
+	if err := cbor.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("invalid payload: %v", err)
+	}
+	if header.Version == nil {
+		return nil, errors.New("invalid payload: no version")
+	}
+	if err := checkPayloadVersion(*header.Version); err != nil {
+		return nil, err
+	}
+
+	payload := &Payload{}
+	if err := cbor.Unmarshal(data, payload); err != nil {
+		return nil, err
+	}
+	return checkDecodedPayload(payload)
+}
+
+// MarshalCBOR serializes the associated MetricSet collected data to CBOR,
+// using the same structure as MarshalJSON.
+func (ms *MetricSet) MarshalCBOR() ([]byte, error) {
+	raw := make(map[string]map[string]interface{}, len(ms.Content))
+	for _, md := range ms.unique() {
+		raw[md.Name] = md.Fields
+	}
+
+	var buf bytes.Buffer
+	if err := cbor.NewEncoder(&buf, cbor.CoreDetEncOptions()).Encode(raw); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalCBOR deserializes the provided CBOR data into the associated
+// MetricSet like UnmarshalJSON.
+func (ms *MetricSet) UnmarshalCBOR(data []byte) error {
+	var raw map[string]map[string]interface{}
+	if err := cbor.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	return ms.setFields(raw)
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEncodings(t *testing.T) {
+	ms, err := newTestRegistry().Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+	envelope := NewEnvelope(ms, time.Unix(1561975200, 0).UTC(), 1, ConsentFull)
+
+	sizes := make(map[string]int)
+	for _, encoding := range []string{EncodingJSON, EncodingCBOR} {
+		for _, version := range []int{PayloadV2, PayloadV3} {
+			encoder, err := NewEncoder(encoding)
+			if err != nil {
+				t.Fatalf("failed to create encoder %v: %v", encoding, err)
+			}
+			var buf bytes.Buffer
+			if err := encoder.Encode(&buf, &Payload{Version: version, Envelope: envelope, Stats: ms}); err != nil {
+				t.Fatalf("failed to encode %v v%d: %v", encoding, version, err)
+			}
+			sizes[encoding] = buf.Len()
+
+			decoder, err := NewDecoder(encoder.ContentType() + "; charset=utf-8")
+			if err != nil {
+				t.Fatalf("failed to create decoder %v: %v", encoder.ContentType(), err)
+			}
+			payload, err := decoder.Decode(&buf)
+			if err != nil {
+				t.Fatalf("failed to decode %v v%d: %v", encoding, version, err)
+			}
+			if payload.Version != version {
+				t.Errorf("%v: unexpected version: %d", encoding, payload.Version)
+			}
+			if version == PayloadV3 && !reflect.DeepEqual(payload.Envelope, envelope) {
+				t.Errorf("%v: unexpected envelope: %+v", encoding, payload.Envelope)
+			}
+			if !reflect.DeepEqual(payload.Stats, ms) {
+				t.Errorf("%v v%d: decoded stats differ:\n%v\n%v", encoding, version, payload.Stats, ms)
+			}
+		}
+	}
+	if sizes[EncodingCBOR] >= sizes[EncodingJSON] {
+		t.Errorf("cbor payload not smaller than json: %v", sizes)
+	}
+
+	if _, err := NewEncoder("xml"); err == nil {
+		t.Error("expected error for unsupported encoding")
+	}
+	if _, err := NewDecoder("text/plain"); err == nil {
+		t.Error("expected error for unsupported content type")
+	}
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// An endpointList holds an ordered list of submit URLs. The first URL is the
+// primary. After a failover, the last working URL is preferred until the
+// primary retry interval has passed.
+type endpointList struct {
+	mutex sync.Mutex
+
+	urls         []*url.URL
+	current      int
+	since        time.Time
+	primaryRetry time.Duration
+}
+
+func newEndpointList(urls []*url.URL, primaryRetry time.Duration) *endpointList {
+	return &endpointList{
+		urls:         urls,
+		primaryRetry: primaryRetry,
+	}
+}
+
+func parseEndpoints(rawURLs ...string) ([]*url.URL, error) {
+	urls := make([]*url.URL, 0, len(rawURLs))
+	for _, rawURL := range rawURLs {
+		if rawURL == "" {
+			continue
+		}
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, u)
+	}
+	if len(urls) == 0 {
+		return nil, errors.New("no endpoint url")
+	}
+	return urls, nil
+}
+
+// candidates returns the URLs of the associated endpointList in the order they
+// should be tried.
+func (el *endpointList) candidates(now time.Time) []*url.URL {
+	el.mutex.Lock()
+	defer el.mutex.Unlock()
+
+	start := el.current
+	if start != 0 && el.primaryRetry > 0 && now.Sub(el.since) >= el.primaryRetry {
+		// Time to give the primary another chance.
+		start = 0
+	}
+
+	urls := make([]*url.URL, 0, len(el.urls))
+	urls = append(urls, el.urls[start:]...)
+	urls = append(urls, el.urls[:start]...)
+	return urls
+}
+
+// succeeded marks the provided URL as working, making it the preferred URL.
+func (el *endpointList) succeeded(u *url.URL, now time.Time) {
+	el.mutex.Lock()
+	defer el.mutex.Unlock()
+
+	for idx, candidate := range el.urls {
+		if candidate == u {
+			if idx != el.current || now.Sub(el.since) >= el.primaryRetry {
+				// Switched, or primary was retried without success.
+				el.since = now
+			}
+			el.current = idx
+			return
+		}
+	}
+}
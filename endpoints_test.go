@@ -0,0 +1,100 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func mustNewTestEndpointList(rawURLs ...string) *endpointList {
+	urls, err := parseEndpoints(rawURLs...)
+	if err != nil {
+		panic(err)
+	}
+	return newEndpointList(urls, time.Hour)
+}
+
+func newTestKSurveyClient(client *http.Client, rawURLs ...string) *kSurveyClient {
+	return &kSurveyClient{
+		endpoints: mustNewTestEndpointList(rawURLs...),
+		gatherer:  NewRegistry(),
+		client:    client,
+		logger:    DefaultLogger,
+		metrics:   NewClientMetrics(),
+	}
+}
+
+func TestEndpointFailover(t *testing.T) {
+	primaryStatus := int32(http.StatusServiceUnavailable)
+	var primaryHits, secondaryHits uint32
+	hits := func() (uint32, uint32) {
+		return atomic.LoadUint32(&primaryHits), atomic.LoadUint32(&secondaryHits)
+	}
+	primary := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddUint32(&primaryHits, 1)
+		rw.WriteHeader(int(atomic.LoadInt32(&primaryStatus)))
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddUint32(&secondaryHits, 1)
+	}))
+	defer secondary.Close()
+
+	ksv := newTestKSurveyClient(http.DefaultClient, primary.URL, secondary.URL)
+
+	if err := ksv.Do(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p, s := hits(); p != 1 || s != 1 {
+		t.Fatalf("unexpected hits after failover: %d/%d", p, s)
+	}
+
+	// Sticky preference for the last working endpoint.
+	if err := ksv.Do(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p, s := hits(); p != 1 || s != 2 {
+		t.Fatalf("unexpected hits with sticky endpoint: %d/%d", p, s)
+	}
+
+	// Primary is retried after the primary retry interval.
+	atomic.StoreInt32(&primaryStatus, http.StatusOK)
+	ksv.endpoints.since = time.Now().Add(-2 * time.Hour)
+	if err := ksv.Do(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p, s := hits(); p != 2 || s != 2 {
+		t.Fatalf("unexpected hits after primary retry: %d/%d", p, s)
+	}
+	if ksv.endpoints.current != 0 {
+		t.Errorf("primary is not preferred after successful retry")
+	}
+
+	// No failover on client errors.
+	atomic.StoreInt32(&primaryStatus, http.StatusBadRequest)
+	if err := ksv.Do(context.Background()); err == nil {
+		t.Fatal("expected error for client error status")
+	}
+	if p, s := hits(); p != 3 || s != 2 {
+		t.Fatalf("unexpected hits after client error: %d/%d", p, s)
+	}
+}
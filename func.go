@@ -0,0 +1,166 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"fmt"
+	"math"
+)
+
+// A ValueFunc is a Metric which value is provided by a callback when it is
+// written. It is also a Collector collecting itself, like ConstMap. Callbacks
+// can be called concurrently with other callbacks.
+type ValueFunc interface {
+	Metric
+	Collector
+}
+
+type valueFunc struct {
+	desc      *Desc
+	valueType ValueType
+	function  func() (interface{}, error)
+
+	selfCollector
+}
+
+// newValueFunc creates a valueFunc with a copy of the provided Desc, which
+// ValueType must be one of the provided ValueTypes and defaults to the first.
+func newValueFunc(desc *Desc, valueTypes []ValueType, defaultMode Mode) (*valueFunc, error) {
+	d := *desc
+	if d.ValueType == "" {
+		d.ValueType = valueTypes[0]
+	}
+	if d.Mode == NoMode {
+		d.Mode = defaultMode
+	}
+	if err := validateName(d.Name); err != nil {
+		return nil, err
+	}
+	supported := false
+	for _, valueType := range valueTypes {
+		if d.ValueType == valueType {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return nil, fmt.Errorf("metric %q: unsupported function type %q", d.Name, d.ValueType)
+	}
+	vf := &valueFunc{
+		desc:      &d,
+		valueType: d.ValueType,
+	}
+	vf.init(vf)
+	return vf, nil
+}
+
+func mustNewValueFunc(vf ValueFunc, err error) ValueFunc {
+	if err != nil {
+		panic(err)
+	}
+	return vf
+}
+
+// NewGaugeFunc creates a ValueFunc with the provided Desc which value is
+// returned by the provided function. The ValueType of the Desc defaults to
+// FloatValue, its Mode to GaugeMode. If the Desc declares IntValue, the value
+// is converted to an integer by truncation. Other ValueTypes are rejected.
+// Values which are not finite fail to write.
+func NewGaugeFunc(desc *Desc, function func() float64) (ValueFunc, error) {
+	return newNumberFunc(desc, GaugeMode, function)
+}
+
+// MustNewGaugeFunc is like NewGaugeFunc but panics if an error occurs.
+func MustNewGaugeFunc(desc *Desc, function func() float64) ValueFunc {
+	return mustNewValueFunc(NewGaugeFunc(desc, function))
+}
+
+// NewCounterFunc is like NewGaugeFunc but the Mode of the Desc defaults to
+// CounterMode. The provided function should return a value which never
+// decreases.
+func NewCounterFunc(desc *Desc, function func() float64) (ValueFunc, error) {
+	return newNumberFunc(desc, CounterMode, function)
+}
+
+// MustNewCounterFunc is like NewCounterFunc but panics if an error occurs.
+func MustNewCounterFunc(desc *Desc, function func() float64) ValueFunc {
+	return mustNewValueFunc(NewCounterFunc(desc, function))
+}
+
+func newNumberFunc(desc *Desc, defaultMode Mode, function func() float64) (ValueFunc, error) {
+	if function == nil {
+		return nil, fmt.Errorf("metric %q: no function", desc.Name)
+	}
+	vf, err := newValueFunc(desc, []ValueType{FloatValue, IntValue}, defaultMode)
+	if err != nil {
+		return nil, err
+	}
+	if vf.valueType == IntValue {
+		vf.function = func() (interface{}, error) {
+			value := function()
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				return nil, fmt.Errorf("metric %q: float value %v is not finite", vf.desc.Name, value)
+			}
+			return int64(value), nil
+		}
+	} else {
+		vf.function = func() (interface{}, error) {
+			return function(), nil
+		}
+	}
+	return vf, nil
+}
+
+// NewStringFunc creates a ValueFunc with the provided Desc which string value
+// is returned by the provided function. The ValueType of the Desc must be
+// empty or StringValue.
+func NewStringFunc(desc *Desc, function func() string) (ValueFunc, error) {
+	if function == nil {
+		return nil, fmt.Errorf("metric %q: no function", desc.Name)
+	}
+	vf, err := newValueFunc(desc, []ValueType{StringValue}, NoMode)
+	if err != nil {
+		return nil, err
+	}
+	vf.function = func() (interface{}, error) {
+		return function(), nil
+	}
+	return vf, nil
+}
+
+// MustNewStringFunc is like NewStringFunc but panics if an error occurs.
+func MustNewStringFunc(desc *Desc, function func() string) ValueFunc {
+	return mustNewValueFunc(NewStringFunc(desc, function))
+}
+
+func (vf *valueFunc) Write(md *MetricData) error {
+	value, err := vf.function()
+	if err != nil {
+		return err
+	}
+	m, err := newConst(vf.desc, vf.valueType, value)
+	if err != nil {
+		return err
+	}
+	return m.Write(md)
+}
+
+// Describe sends the Desc of the associated valueFunc without calling its
+// function.
+func (vf *valueFunc) Describe(ch chan<- *Desc) {
+	ch <- vf.desc
+}
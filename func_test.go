@@ -0,0 +1,116 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"math"
+	"sync/atomic"
+	"testing"
+)
+
+func TestValueFuncs(t *testing.T) {
+	var calls int64
+	users := 3.0
+	status := "starting"
+	reg := NewRegistry()
+	reg.MustRegister(
+		MustNewGaugeFunc(NewDesc("load", "Current load", "", NoMode), func() float64 {
+			atomic.AddInt64(&calls, 1)
+			return 0.25
+		}),
+		MustNewGaugeFunc(NewDesc("usercnt_active", "Active users", IntValue, NoMode), func() float64 {
+			atomic.AddInt64(&calls, 1)
+			return users
+		}),
+		MustNewCounterFunc(NewDesc("requests", "", "", NoMode), func() float64 {
+			atomic.AddInt64(&calls, 1)
+			return 100
+		}),
+		MustNewStringFunc(NewDesc("status", "Status", "", NoMode), func() string {
+			atomic.AddInt64(&calls, 1)
+			return status
+		}),
+	)
+	if calls != 0 {
+		t.Errorf("functions called when registering: %d", calls)
+	}
+
+	users = 5
+	status = "running"
+	ms, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+	for _, expected := range []struct {
+		name  string
+		value interface{}
+		vt    ValueType
+		mode  Mode
+	}{
+		{"load", 0.25, FloatValue, GaugeMode},
+		{"usercnt_active", int64(5), IntValue, GaugeMode},
+		{"requests", 100.0, FloatValue, CounterMode},
+		{"status", "running", StringValue, NoMode},
+	} {
+		md := ms.Get(expected.name)
+		if md == nil || md.Value() != expected.value || md.ValueType() != expected.vt || md.Mode() != expected.mode {
+			t.Errorf("unexpected metric %s: %v", expected.name, md)
+		}
+	}
+	if calls != 4 {
+		t.Errorf("unexpected number of function calls: %d", calls)
+	}
+
+	for _, valueType := range []ValueType{FloatValue, IntValue} {
+		for _, value := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+			failing := NewRegistry()
+			failing.MustRegister(MustNewGaugeFunc(NewDesc("broken", "", valueType, NoMode), func() float64 {
+				return value
+			}))
+			if ms, err := failing.Gather(); err == nil || ms.Get("broken") != nil {
+				t.Errorf("expected error for non-finite %s value %v", valueType, value)
+			}
+		}
+	}
+}
+
+func TestValueFuncInvalid(t *testing.T) {
+	number := func() float64 { return 1 }
+	for _, desc := range []*Desc{
+		NewDesc("", "", "", NoMode),
+		NewDesc("bad-name", "", "", NoMode),
+		NewDesc("enabled", "", BoolValue, NoMode),
+		NewDesc("status", "", StringValue, NoMode),
+	} {
+		if _, err := NewGaugeFunc(desc, number); err == nil {
+			t.Errorf("expected error for gauge function %q of type %q", desc.Name, desc.ValueType)
+		}
+	}
+	if _, err := NewCounterFunc(NewDesc("requests", "", "", NoMode), nil); err == nil {
+		t.Error("expected error for counter without function")
+	}
+	if _, err := NewStringFunc(NewDesc("status", "", IntValue, NoMode), func() string { return "" }); err == nil {
+		t.Error("expected error for string function of int type")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for invalid string function")
+		}
+	}()
+	MustNewStringFunc(NewDesc("bad-name", "", "", NoMode), func() string { return "" })
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// A CollectorError is returned when a Collector failed while gathering. If
+// the failure was caused by a single Metric, Metric identifies that Metric.
+type CollectorError struct {
+	Collector string
+	Metric    string
+	Err       error
+}
+
+func (err *CollectorError) Error() string {
+	if err.Metric != "" {
+		return fmt.Sprintf("collector %s failed to write metric %s: %v", err.Collector, err.Metric, err.Err)
+	}
+	return fmt.Sprintf("collector %s failed: %v", err.Collector, err.Err)
+}
+
+// Timeout reports if the associated CollectorError was caused by the collector
+// not finishing in time.
+func (err *CollectorError) Timeout() bool {
+	return err.Err == context.DeadlineExceeded
+}
+
+// collectedMetrics holds the MetricData collected from a Collector.
+type collectedMetrics struct {
+	collector string
+	content   []*MetricData
+	errs      MultiError
+}
+
+// collect calls the provided Collector and writes the collected Metrics. If
+// the Collector panics or does not finish before the provided Context is done
+// or the provided timeout is reached, no metrics but an error is returned.
+// Metrics which fail to write are skipped with an error each.
+func collect(ctx context.Context, collector Collector, idx int, timeout time.Duration) *collectedMetrics {
+	cm := &collectedMetrics{
+		collector: collectorName(collector, idx),
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	metricChan := make(chan Metric)
+	panicChan := make(chan interface{}, 1)
+	go func() {
+		defer close(metricChan)
+		defer func() {
+			if r := recover(); r != nil {
+				panicChan <- r
+			}
+		}()
+		if cc, ok := collector.(CollectorContext); ok {
+			cc.CollectContext(ctx, metricChan)
+		} else {
+			collector.Collect(metricChan)
+		}
+	}()
+
+	content := make([]*MetricData, 0)
+	for {
+		select {
+		case metric, ok := <-metricChan:
+			if !ok {
+				select {
+				case r := <-panicChan:
+					cm.errs = MultiError{&CollectorError{
+						Collector: cm.collector,
+						Err:       fmt.Errorf("panic: %v", r),
+					}}
+				default:
+					cm.content = content
+				}
+				return cm
+			}
+			md, err := writeMetric(metric)
+			if err != nil {
+				name := md.Name
+				if name == "" {
+					name = fmt.Sprintf("%T", metric)
+				}
+				cm.errs = append(cm.errs, &CollectorError{
+					Collector: cm.collector,
+					Metric:    name,
+					Err:       err,
+				})
+				continue
+			}
+			if md.Name == "" {
+				// Metric chose to write nothing, skip.
+				continue
+			}
+			content = append(content, md)
+
+		case <-ctx.Done():
+			cm.errs = MultiError{&CollectorError{
+				Collector: cm.collector,
+				Err:       ctx.Err(),
+			}}
+			go func() {
+				// Drain, so the Collector is not blocked forever.
+				for range metricChan {
+				}
+			}()
+			return cm
+		}
+	}
+}
+
+// writeMetric writes the provided Metric into new MetricData, converting
+// panics into errors.
+func writeMetric(metric Metric) (md *MetricData, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	md = &MetricData{}
+	err = metric.Write(md)
+	return md, err
+}
+
+func collectorName(collector Collector, idx int) string {
+	if stringer, ok := collector.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return fmt.Sprintf("%T[%d]", collector, idx)
+}
+
+var collectorErrorsDesc = NewDesc("collector_errors", "Number of errors while gathering", IntValue, GaugeMode)
+
+// newCollectorErrorsMetric creates a Metric holding the number and messages of
+// the provided errors.
+func newCollectorErrorsMetric(errs MultiError) (Metric, error) {
+	msgs := make([]string, len(errs))
+	for idx, err := range errs {
+		msgs[idx] = err.Error()
+	}
+	fields, err := collectorErrorsDesc.fields(IntValue, int64(len(errs)))
+	if err != nil {
+		return nil, err
+	}
+	fields["errors"] = msgs
+	return NewConstMap(collectorErrorsDesc.Name, fields)
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type sleepingCollector struct {
+	metric Metric
+	delay  time.Duration
+}
+
+func (c *sleepingCollector) Collect(ch chan<- Metric) {
+	time.Sleep(c.delay)
+	ch <- c.metric
+}
+
+type panickingCollector struct {
+}
+
+func (c *panickingCollector) Collect(ch chan<- Metric) {
+	ch <- MustNewConstInt(NewDesc("before_panic", "", IntValue, GaugeMode), 1)
+	panic("collector is broken")
+}
+
+type failingMetric struct {
+	name string
+}
+
+func (m *failingMetric) Write(md *MetricData) error {
+	md.Name = m.name
+	return errors.New("metric is broken")
+}
+
+type contextCollector struct {
+	cancelled chan error
+}
+
+func (c *contextCollector) Collect(ch chan<- Metric) {
+	panic("Collect called although CollectContext is implemented")
+}
+
+func (c *contextCollector) CollectContext(ctx context.Context, ch chan<- Metric) {
+	<-ctx.Done()
+	c.cancelled <- ctx.Err()
+}
+
+func TestRegistryGatherParallel(t *testing.T) {
+	reg := NewRegistry(WithMaxConcurrency(5))
+	for i := 0; i < 5; i++ {
+		reg.MustRegister(&sleepingCollector{
+			metric: MustNewConstInt(NewDesc(fmt.Sprintf("metric_%d", i), "", IntValue, GaugeMode), int64(i)),
+			delay:  200 * time.Millisecond,
+		})
+	}
+
+	started := time.Now()
+	ms, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+	if d := time.Since(started); d > 800*time.Millisecond {
+		t.Errorf("gather was not run in parallel, took %v", d)
+	}
+	if len(ms.Content) != 5 {
+		t.Errorf("unexpected number of metrics: %d", len(ms.Content))
+	}
+	for idx, md := range ms.Content {
+		if md.Name != fmt.Sprintf("metric_%d", idx) {
+			t.Errorf("unexpected metric order: %v at %d", md.Name, idx)
+		}
+	}
+}
+
+func TestRegistryGatherFailingCollectors(t *testing.T) {
+	reg := NewRegistry(WithCollectorTimeout(100 * time.Millisecond))
+	reg.MustRegister(
+		MustNewConstInt(testUsercntDesc, 1),
+		&sleepingCollector{
+			metric: MustNewConstInt(NewDesc("slow", "", IntValue, GaugeMode), 1),
+			delay:  time.Second,
+		},
+		&panickingCollector{},
+	)
+
+	started := time.Now()
+	ms, err := reg.Gather()
+	if d := time.Since(started); d > 500*time.Millisecond {
+		t.Errorf("gather did not give up slow collector, took %v", d)
+	}
+	errs, ok := err.(MultiError)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if collectorErr, ok := errs[0].(*CollectorError); !ok || !collectorErr.Timeout() || collectorErr.Collector != "*ksurveyclient.sleepingCollector[1]" {
+		t.Errorf("unexpected timeout error: %v", errs[0])
+	}
+	if collectorErr, ok := errs[1].(*CollectorError); !ok || collectorErr.Timeout() || collectorErr.Collector != "*ksurveyclient.panickingCollector[2]" {
+		t.Errorf("unexpected panic error: %v", errs[1])
+	}
+
+	if ms == nil || len(ms.Content) != 1 || ms.Content[0].Name != "usercnt_active" {
+		t.Errorf("unexpected partial metrics: %v", ms)
+	}
+}
+
+func TestRegistryGatherContext(t *testing.T) {
+	cc := &contextCollector{
+		cancelled: make(chan error, 1),
+	}
+	reg := NewRegistry(WithCollectorTimeout(0))
+	reg.MustRegister(MustNewConstInt(testUsercntDesc, 1), cc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	ms, err := reg.GatherContext(ctx)
+	if collectorErr, ok := err.(*CollectorError); !ok || !collectorErr.Timeout() {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if ms == nil || len(ms.Content) != 1 {
+		t.Errorf("unexpected partial metrics: %v", ms)
+	}
+
+	select {
+	case err := <-cc.cancelled:
+		if err != context.DeadlineExceeded {
+			t.Errorf("unexpected collector context error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("collector context was not cancelled")
+	}
+}
+
+func TestRegistryGatherMetricErrors(t *testing.T) {
+	newRegistry := func(opts ...RegistryOption) *Registry {
+		reg := NewRegistry(opts...)
+		reg.MustRegister(
+			MustNewConstInt(testUsercntDesc, 1),
+			newTestCollector(&failingMetric{"broken"}, &failingMetric{}),
+		)
+		return reg
+	}
+
+	ms, err := newRegistry().Gather()
+	errs, ok := err.(MultiError)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if collectorErr, ok := errs[0].(*CollectorError); !ok || collectorErr.Metric != "broken" || collectorErr.Collector != "*ksurveyclient.testCollector[1]" {
+		t.Errorf("unexpected metric error: %v", errs[0])
+	}
+	if collectorErr, ok := errs[1].(*CollectorError); !ok || collectorErr.Metric != "*ksurveyclient.failingMetric" {
+		t.Errorf("unexpected metric error: %v", errs[1])
+	}
+	if ms == nil || len(ms.Content) != 1 {
+		t.Errorf("unexpected partial metrics: %v", ms)
+	}
+
+	ms, err = newRegistry(WithErrorPolicy(FailOnError)).Gather()
+	if err == nil || ms != nil {
+		t.Errorf("expected failed gather, got %v, %v", ms, err)
+	}
+
+	ms, _ = newRegistry(WithCollectorErrorsMetric(true)).Gather()
+	if ms == nil || len(ms.Content) != 2 {
+		t.Fatalf("unexpected metrics: %v", ms)
+	}
+	md := ms.Get("collector_errors")
+	if md == nil {
+		t.Fatal("missing collector_errors metric")
+	}
+	if msgs, _ := md.Fields["errors"].([]string); md.Value() != int64(2) || len(msgs) != 2 {
+		t.Errorf("unexpected collector_errors metric: %v", md.Fields)
+	}
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// A Gatherer gathers Metrics into a MetricSet. It is implemented by Registry
+// and Gatherers.
+type Gatherer interface {
+	Gather() (*MetricSet, error)
+}
+
+// GathererContext is the interface optionally implemented by Gatherers which
+// support cancellation.
+type GathererContext interface {
+	Gatherer
+	GatherContext(ctx context.Context) (*MetricSet, error)
+}
+
+// Gatherers is a list of Gatherers which is itself a Gatherer. It merges the
+// MetricSets of all its Gatherers into one.
+type Gatherers []Gatherer
+
+// Gather calls Gather of all Gatherers concurrently and merges the results.
+// Metrics with the same name gathered by multiple Gatherers are reported with
+// a DuplicateMetricError and only the Metric of the first Gatherer is kept.
+// Errors are returned together with all successfully gathered metrics,
+// wrapped into a MultiError if there is more than one.
+func (gs Gatherers) Gather() (*MetricSet, error) {
+	return gs.GatherContext(context.Background())
+}
+
+// GatherContext is like Gather, passing the provided Context to Gatherers
+// which implement GathererContext.
+func (gs Gatherers) GatherContext(ctx context.Context) (*MetricSet, error) {
+	results := make([]*MetricSet, len(gs))
+	gatherErrs := make([]error, len(gs))
+
+	var wg sync.WaitGroup
+	wg.Add(len(gs))
+	for idx, g := range gs {
+		go func(idx int, g Gatherer) {
+			defer wg.Done()
+			results[idx], gatherErrs[idx] = gatherContext(ctx, g)
+		}(idx, g)
+	}
+	wg.Wait()
+
+	var errs MultiError
+	var content []*MetricData
+	seen := make(map[string]int)
+	for idx, ms := range results {
+		switch err := gatherErrs[idx].(type) {
+		case nil:
+		case MultiError:
+			errs = append(errs, err...)
+		default:
+			errs = append(errs, err)
+		}
+		if ms == nil {
+			continue
+		}
+		for _, md := range ms.Content {
+			if first, ok := seen[md.Name]; ok {
+				if first != idx {
+					errs = append(errs, &DuplicateMetricError{
+						Name: md.Name,
+						Collectors: []string{
+							gathererName(gs[first], first),
+							gathererName(gs[idx], idx),
+						},
+					})
+				}
+				continue
+			}
+			seen[md.Name] = idx
+			content = append(content, md)
+		}
+	}
+
+	return NewMetricSet(content), errs.MaybeUnwrap()
+}
+
+// gatherContext gathers with the provided Gatherer, using GatherContext if
+// supported.
+func gatherContext(ctx context.Context, g Gatherer) (*MetricSet, error) {
+	if gc, ok := g.(GathererContext); ok {
+		return gc.GatherContext(ctx)
+	}
+	return g.Gather()
+}
+
+// gathererName returns a name for the provided Gatherer at the provided
+// index to be used in error messages.
+func gathererName(g Gatherer, idx int) string {
+	if stringer, ok := g.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return fmt.Sprintf("%T[%d]", g, idx)
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var errTest = errors.New("test error")
+
+type contextGatherer struct {
+	ctx context.Context
+}
+
+func (g *contextGatherer) Gather() (*MetricSet, error) {
+	return nil, errors.New("gather without context")
+}
+
+func (g *contextGatherer) GatherContext(ctx context.Context) (*MetricSet, error) {
+	if ctx != g.ctx {
+		return nil, errors.New("unexpected context")
+	}
+	return nil, errTest
+}
+
+func TestGatherers(t *testing.T) {
+	app := newTestRegistry()
+	lib := NewRegistry()
+	lib.MustRegister(
+		MustNewConstInt(NewDesc("lib_requests", "", IntValue, CounterMode), 7),
+		NewProgramCollector("kopano-lib", "0.1.0", nil),
+	)
+
+	ms, err := Gatherers{app, lib}.Gather()
+	if err == nil {
+		t.Fatal("expected error for duplicate metrics")
+	}
+	errs, ok := err.(MultiError)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, err := range errs {
+		dupErr, ok := err.(*DuplicateMetricError)
+		if !ok || !reflect.DeepEqual(dupErr.Collectors, []string{"*ksurveyclient.Registry[0]", "*ksurveyclient.Registry[1]"}) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	expected := []string{"enabled", "lib_requests", "program_name", "program_version", "ratio", "server_guid", "usercnt_active"}
+	if names := ms.Names(); !reflect.DeepEqual(names, expected) {
+		t.Errorf("unexpected names: %v", names)
+	}
+	if md := ms.Get("program_name"); md.Value() != "kopano-test" {
+		t.Errorf("metric of first gatherer was not kept: %v", md.Value())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ms, err = Gatherers{&contextGatherer{ctx: ctx}, lib}.GatherContext(ctx)
+	if err != errTest {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if ms == nil || ms.Get("lib_requests") == nil {
+		t.Errorf("unexpected metric set: %v", ms)
+	}
+}
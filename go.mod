@@ -3,6 +3,7 @@ module stash.kopano.io/kgol/ksurveyclient-go
 go 1.12
 
 require (
+	github.com/fxamacker/cbor v1.5.1
 	github.com/prometheus/client_golang v1.1.0
 	github.com/prometheus/client_model v0.0.0-20190129233127-fd36f4220a90
 	golang.org/x/crypto v0.0.0-20190308221718-c2843e01d9a2
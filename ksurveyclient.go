@@ -20,12 +20,13 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"sync/atomic"
 	"time"
 )
 
@@ -40,45 +41,95 @@ func init() {
 }
 
 type kSurveyClient struct {
-	url        *url.URL
+	// NOTE: Keep first for 64-bit aligned atomic access.
+	sequence uint64
+
+	endpoints  *endpointList
+	discoverer *discoverer
 	startDelay uint64
 	errorDelay uint64
 	interval   uint64
 	userAgent  string
 
-	registry *Registry
+	requestTimeout time.Duration
+
+	payloadVersion int
+	consentLevel   string
+	encoder        Encoder
+	limits         payloadLimits
 
-	client *http.Client
-	logger logger
+	gatherer Gatherer
+
+	client  *http.Client
+	logger  Logger
+	metrics *ClientMetrics
 }
 
 // StartKSurveyClient starts a new survey client using the provided Context and
-// the provid Config.
-func StartKSurveyClient(ctx context.Context, config *Config, registry *Registry) error {
+// the provid Config, submitting the metrics gathered by the provided Gatherer.
+// If the Gatherer is nil, the DefaultRegistry is used. Use Gatherers to submit
+// the metrics of multiple Registries.
+func StartKSurveyClient(ctx context.Context, config *Config, gatherer Gatherer) error {
 	var err error
 
 	if config == nil {
 		config = DefaultConfig
 	}
-	if registry == nil {
-		registry = DefaultRegistry
+	if gatherer == nil {
+		gatherer = DefaultRegistry
+	} else if reg, ok := gatherer.(*Registry); ok && reg == nil {
+		gatherer = DefaultRegistry
 	}
 
 	ksv := &kSurveyClient{
 		startDelay: config.StartDelay,
+		errorDelay: config.ErrorDelay,
 		interval:   config.Interval,
 		userAgent:  config.UserAgent,
 
-		registry: registry,
+		requestTimeout: secondsOrDefault(config.RequestTimeout, defaultRequestTimeout),
+
+		payloadVersion: config.PayloadVersion,
+		consentLevel:   config.ConsentLevel,
+		limits: payloadLimits{
+			maxPayloadSize: int(config.MaxPayloadSize),
+			maxMetrics:     int(config.MaxMetrics),
+			maxValueLength: int(config.MaxValueLength),
+		},
 
-		logger: config.Logger,
+		gatherer: gatherer,
+
+		logger:  config.LeveledLogger,
+		metrics: config.Metrics,
 	}
 	if ksv.logger == nil {
-		ksv.logger = DefaultLogger
+		if config.Logger != nil {
+			ksv.logger = NewStdLogger(config.Logger)
+		} else {
+			ksv.logger = DefaultLogger
+		}
+	}
+	if ksv.metrics == nil {
+		ksv.metrics = DefaultClientMetrics
 	}
-	if ksv.url, err = url.Parse(config.URL); err != nil {
+	switch ksv.payloadVersion {
+	case 0, PayloadV2, PayloadV3:
+	default:
+		return fmt.Errorf("unsupported payload version: %d", ksv.payloadVersion)
+	}
+	ksv.encoder, err = NewEncoder(config.Encoding)
+	if err != nil {
 		return err
 	}
+	rawURLs := config.Endpoints
+	if len(rawURLs) == 0 {
+		rawURLs = []string{config.URL}
+	}
+	urls, err := parseEndpoints(rawURLs...)
+	if err != nil {
+		return err
+	}
+	ksv.endpoints = newEndpointList(urls, secondsOrDefault(config.PrimaryRetryInterval, defaultPrimaryRetryInterval))
 
 	if config.HTTPClient != nil {
 		if config.Insecure {
@@ -93,21 +144,31 @@ func StartKSurveyClient(ctx context.Context, config *Config, registry *Registry)
 			}
 		}
 		ksv.client = &http.Client{
-			Timeout: 60 * time.Second,
 			Transport: &http.Transport{
 				DialContext: (&net.Dialer{
-					Timeout:   30 * time.Second,
+					Timeout:   secondsOrDefault(config.ConnectTimeout, defaultConnectTimeout),
 					KeepAlive: -1,
 					DualStack: true,
 				}).DialContext,
 				DisableKeepAlives:     true,
-				TLSHandshakeTimeout:   10 * time.Second,
+				TLSHandshakeTimeout:   secondsOrDefault(config.TLSHandshakeTimeout, defaultTLSHandshakeTimeout),
 				ExpectContinueTimeout: 1 * time.Second,
 				TLSClientConfig:       tlsClientConfig,
 			},
 		}
 	}
 
+	if config.Discovery != DiscoveryNone {
+		var resolver srvResolver
+		if config.Resolver != nil {
+			resolver = config.Resolver
+		}
+		ksv.discoverer, err = newDiscoverer(config.Discovery, config.DiscoveryDomain, urls[0].Path, resolver, ksv.client, urls, ksv.endpoints.primaryRetry)
+		if err != nil {
+			return err
+		}
+	}
+
 	go ksv.Run(ctx)
 
 	return nil
@@ -127,61 +188,162 @@ func (ksv *kSurveyClient) Run(ctx context.Context) {
 	var interval uint64
 	for {
 		interval = ksv.interval
-		err = ksv.Do()
-		if err != nil && ksv.logger != nil {
-			ksv.logger.Printf("ksurveyclient failed: %v", err)
+		err = ksv.Do(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				// Context done, exit.
+				return
+			}
+			ksv.logger.Warn("survey submission failed", errorFields(err)...)
 			if ksv.errorDelay > 0 {
 				interval = ksv.errorDelay
 			}
 		}
 		if interval == 0 {
 			// Done.
+			ksv.logger.Debug("survey client done, no interval")
 			return
 		}
+		next := time.Duration(interval) * time.Second
+		ksv.logger.Debug("survey client next run scheduled", "next_run", time.Now().Add(next), "interval", next)
 		select {
 		case <-ctx.Done():
 			// Context done, exit.
 			return
-		case <-time.After(time.Duration(ksv.interval) * time.Second):
+		case <-time.After(next):
 			// Continue after interval.
 		}
 	}
 }
 
-func (ksv *kSurveyClient) Do() error {
+func (ksv *kSurveyClient) Do(ctx context.Context) error {
 	if !SurveyClientEnabled {
 		// Global disable flag - do nothing.
 		return nil
 	}
 
-	ms, err := ksv.registry.Gather()
+	if ksv.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ksv.requestTimeout)
+		defer cancel()
+	}
+
+	ksv.metrics.attempted()
+	err := ksv.do(ctx)
 	if err != nil {
+		ksv.metrics.failed()
 		return err
 	}
-	payload := kSurveyPayloadV2{
-		Version: 2,
+	ksv.metrics.succeeded(time.Now())
+
+	return nil
+}
+
+func (ksv *kSurveyClient) do(ctx context.Context) error {
+	started := time.Now()
+	ms, err := gatherContext(ctx, ksv.gatherer)
+	ksv.metrics.gathered(time.Since(started))
+	if err != nil {
+		if ms == nil {
+			return err
+		}
+		ksv.logger.Warn("survey gather incomplete, submitting partial data", "error", err)
+	}
+	payload := &Payload{
+		Version: ksv.payloadVersion,
 		Stats:   ms,
 	}
-	var buf bytes.Buffer
-	enc := json.NewEncoder(&buf)
-	enc.SetIndent("", "  ")
-	if err := enc.Encode(payload); err != nil {
+	if payload.Version == 0 {
+		payload.Version = PayloadV2
+	}
+	if payload.Version >= PayloadV3 {
+		sequence := atomic.AddUint64(&ksv.sequence, 1)
+		payload.Envelope = NewEnvelope(ms, started, sequence, ksv.consentLevel)
+	}
+	encoder := ksv.encoder
+	if encoder == nil {
+		encoder = &jsonEncoding{}
+	}
+	data, dropped, err := ksv.limits.encode(encoder, payload)
+	if err != nil {
 		return err
 	}
+	if dropped > 0 {
+		ksv.logger.Warn("survey payload limits exceeded, metrics dropped", "dropped", dropped)
+	}
+	ksv.metrics.payload(len(data))
+
+	endpoints := ksv.endpoints
+	if ksv.discoverer != nil {
+		if discovered, discoverErr := ksv.discoverer.discover(ctx); discoverErr == nil {
+			endpoints = discovered
+		} else {
+			ksv.logger.Warn("survey endpoint discovery failed, using configured endpoints", "error", discoverErr)
+		}
+	}
+
+	var lastErr error
+	for _, u := range endpoints.candidates(time.Now()) {
+		retry, err := ksv.submit(ctx, u, encoder.ContentType(), data)
+		if err == nil {
+			endpoints.succeeded(u, time.Now())
+			return nil
+		}
+		if !retry || ctx.Err() != nil {
+			return err
+		}
+		ksv.logger.Warn("survey endpoint failed, trying next", append([]interface{}{"url", u.String()}, errorFields(err)...)...)
+		lastErr = err
+	}
+
+	return lastErr
+}
 
-	req, err := http.NewRequest(http.MethodPost, ksv.url.String(), &buf)
+// submit posts the provided payload with the provided content type to the
+// provided URL. The returned bool reports if a failed submission should be
+// retried with another endpoint.
+func (ksv *kSurveyClient) submit(ctx context.Context, u *url.URL, contentType string, payload []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	req = req.WithContext(ctx)
 	req.Header.Set("X-Kopano-Stats-Request", "1")
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", contentType)
 	if ksv.userAgent != "" {
 		req.Header.Set("User-Agent", ksv.userAgent)
 	}
 
 	resp, err := ksv.client.Do(req)
 	if err != nil {
-		return err
+		return true, err
 	}
 
 	defer resp.Body.Close()
 
-	return err
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode >= 500, &StatusError{StatusCode: resp.StatusCode}
+	}
+	ksv.logger.Info("survey submitted", "url", req.URL.String(), "status_code", resp.StatusCode, "bytes", req.ContentLength)
+
+	return false, nil
+}
+
+// A StatusError is returned when a submission is answered with an unexpected
+// HTTP response status.
+type StatusError struct {
+	StatusCode int
+}
+
+func (err *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response status: %d", err.StatusCode)
+}
+
+// errorFields returns the log fields of the provided error, including the
+// status code of a StatusError.
+func errorFields(err error) []interface{} {
+	if statusErr, ok := err.(*StatusError); ok {
+		return []interface{}{"error", err, "status_code", statusErr.StatusCode}
+	}
+	return []interface{}{"error", err}
 }
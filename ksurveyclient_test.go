@@ -18,6 +18,7 @@ package ksurveyclient
 
 import (
 	"context"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -31,30 +32,108 @@ func TestMain(m *testing.M) {
 }
 
 func TestStartKSurveyClient(t *testing.T) {
-	ok := false
+	received := make(chan struct{}, 1)
 	ts := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
 		if req.Header.Get("User-Agent") != DefaultConfig.UserAgent {
 			t.Errorf("unexpected User-Agent: %v in request", req.Header.Get("User-Agent"))
 		}
-		// TODO(longsleep): Validate incoming request data.
-		ok = true
+		data, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			t.Errorf("failed to read request: %v", err)
+		} else if err = ValidateJSON(data); err != nil {
+			t.Errorf("invalid request data: %v", err)
+		}
+		select {
+		case received <- struct{}{}:
+		default:
+		}
 	}))
 	defer ts.Close()
 
 	config := DefaultConfig.Clone()
 	config.URL = ts.URL
 	config.HTTPClient = ts.Client()
-	config.Logger = &testingLogger{t}
+	logger := &testingLogger{T: t}
+	defer logger.Close()
+	config.Logger = logger
 
-	err := StartKSurveyClient(context.Background(), config, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := StartKSurveyClient(ctx, config, nil)
 	if err != nil {
 		t.Error("failed to start survey client", err)
 	}
 
 	select {
+	case <-received:
 	case <-time.After(5 * time.Second):
+		t.Error("request was not received")
+	}
+}
+
+func TestStartKSurveyClientConfigLiteral(t *testing.T) {
+	received := make(chan struct{}, 1)
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		select {
+		case received <- struct{}{}:
+		default:
+		}
+	}))
+	defer ts.Close()
+
+	config := &Config{
+		URL:      ts.URL,
+		Insecure: true,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := StartKSurveyClient(ctx, config, NewRegistry())
+	if err != nil {
+		t.Error("failed to start survey client", err)
 	}
-	if !ok {
+
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
 		t.Error("request was not received")
 	}
 }
+
+func TestKSurveyClientDoCancel(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		select {
+		case <-release:
+		case <-req.Context().Done():
+		}
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	ksv := newTestKSurveyClient(ts.Client(), ts.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	started := time.Now()
+	if err := ksv.Do(ctx); err == nil {
+		t.Error("expected error for cancelled submission")
+	}
+	if d := time.Since(started); d > 2*time.Second {
+		t.Errorf("cancelled submission took too long: %v", d)
+	}
+
+	ksv.requestTimeout = 100 * time.Millisecond
+	started = time.Now()
+	if err := ksv.Do(context.Background()); err == nil {
+		t.Error("expected error for timed out submission")
+	}
+	if d := time.Since(started); d > 2*time.Second {
+		t.Errorf("timed out submission took too long: %v", d)
+	}
+}
@@ -0,0 +1,191 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"unicode/utf8"
+)
+
+// EssentialMetrics lists the names of the metrics which are kept with
+// priority, in this order, when metrics need to be dropped to stay within the
+// payload limits.
+var EssentialMetrics = []string{
+	programNameDesc.Name,
+	programVersionDesc.Name,
+	serverGUIDDesc.Name,
+	machineIDDesc.Name,
+	osreleaseDesc.Name,
+	utsnameDesc.Name,
+	collectorErrorsDesc.Name,
+}
+
+var payloadTruncatedDesc = NewDesc("payload_truncated", "Number of metrics dropped to stay within the payload limits", IntValue, GaugeMode)
+
+// maxDroppedNames is the maximum number of names listed in the
+// payload_truncated metric.
+const maxDroppedNames = 100
+
+// payloadLimits restrict the size of submitted payloads. Zero values disable
+// the respective limit.
+type payloadLimits struct {
+	maxPayloadSize int
+	maxMetrics     int
+	maxValueLength int
+}
+
+// encode encodes the provided Payload with the provided Encoder within the
+// associated limits. String values longer than maxValueLength are cut. Then
+// metrics are dropped until at most maxMetrics are left, including the
+// payload_truncated metric, and the encoded payload is not larger than
+// maxPayloadSize, keeping EssentialMetrics first and all other metrics ordered
+// by name. If anything was dropped or cut, the payload_truncated metric is
+// added, listing the affected names. It returns the encoded payload and the
+// number of dropped metrics.
+func (l *payloadLimits) encode(encoder Encoder, payload *Payload) ([]byte, int, error) {
+	content, truncated := l.prioritize(payload.Stats)
+
+	build := func(n int) (*bytes.Buffer, error) {
+		limited := *payload
+		limited.Stats = NewMetricSet(append([]*MetricData(nil), content[:n]...))
+		if n < len(content) || len(truncated) > 0 {
+			md, err := newPayloadTruncatedMetricData(content[n:], truncated)
+			if err != nil {
+				return nil, err
+			}
+			limited.Stats = NewMetricSet(append(limited.Stats.Content, md))
+		}
+		var buf bytes.Buffer
+		if err := encoder.Encode(&buf, &limited); err != nil {
+			return nil, err
+		}
+		return &buf, nil
+	}
+
+	n := len(content)
+	if l.maxMetrics > 0 && (n > l.maxMetrics || (n == l.maxMetrics && len(truncated) > 0)) {
+		// Reserve a slot for the payload_truncated metric.
+		n = l.maxMetrics - 1
+	}
+	buf, err := build(n)
+	if err != nil {
+		return nil, 0, err
+	}
+	if l.maxPayloadSize > 0 && buf.Len() > l.maxPayloadSize {
+		// Find the largest number of metrics which fits.
+		low, high := 0, n-1
+		var fitting *bytes.Buffer
+		for low <= high {
+			mid := (low + high) / 2
+			candidate, err := build(mid)
+			if err != nil {
+				return nil, 0, err
+			}
+			if candidate.Len() <= l.maxPayloadSize {
+				fitting = candidate
+				n = mid
+				low = mid + 1
+			} else {
+				high = mid - 1
+			}
+		}
+		if fitting == nil {
+			return nil, 0, errors.New("payload exceeds size limit even without metrics")
+		}
+		buf = fitting
+	}
+
+	return buf.Bytes(), len(content) - n, nil
+}
+
+// prioritize returns the unique content of the provided MetricSet with
+// EssentialMetrics first and string values cut to the maximum value length,
+// together with the names of the metrics which values were cut.
+func (l *payloadLimits) prioritize(ms *MetricSet) ([]*MetricData, []string) {
+	content := ms.unique()
+	priority := make(map[string]int, len(EssentialMetrics))
+	for idx, name := range EssentialMetrics {
+		priority[name] = idx - len(EssentialMetrics)
+	}
+	sort.SliceStable(content, func(i, j int) bool {
+		return priority[content[i].Name] < priority[content[j].Name]
+	})
+
+	var truncated []string
+	if l.maxValueLength > 0 {
+		for idx, md := range content {
+			s, ok := md.Value().(string)
+			if !ok || len(s) <= l.maxValueLength {
+				continue
+			}
+			fields := make(map[string]interface{}, len(md.Fields))
+			for key, value := range md.Fields {
+				fields[key] = value
+			}
+			fields["value"] = truncateString(s, l.maxValueLength)
+			content[idx] = &MetricData{
+				Name:   md.Name,
+				Fields: fields,
+			}
+			truncated = append(truncated, md.Name)
+		}
+		sort.Strings(truncated)
+	}
+
+	return content, truncated
+}
+
+func newPayloadTruncatedMetricData(dropped []*MetricData, truncated []string) (*MetricData, error) {
+	names := make([]string, 0, len(dropped))
+	for _, md := range dropped {
+		names = append(names, md.Name)
+	}
+	sort.Strings(names)
+	if len(names) > maxDroppedNames {
+		names = names[:maxDroppedNames]
+	}
+	if len(truncated) > maxDroppedNames {
+		truncated = truncated[:maxDroppedNames]
+	} else if truncated == nil {
+		truncated = []string{}
+	}
+
+	fields, err := payloadTruncatedDesc.fields(IntValue, int64(len(dropped)))
+	if err != nil {
+		return nil, err
+	}
+	fields["dropped"] = names
+	fields["truncated"] = truncated
+	return &MetricData{
+		Name:   payloadTruncatedDesc.Name,
+		Fields: fields,
+	}, nil
+}
+
+// truncateString cuts the provided string to at most max bytes without
+// splitting UTF-8 sequences.
+func truncateString(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newLimitsTestMetricSet() *MetricSet {
+	reg := newTestRegistry()
+	for i := 0; i < 50; i++ {
+		reg.MustRegister(MustNewConstInt(NewDesc(fmt.Sprintf("series_%02d", i), "Some series", IntValue, GaugeMode), int64(i)))
+	}
+	reg.MustRegister(MustNewConstString(NewDesc("long", "", StringValue, NoMode), strings.Repeat("ä", 100)))
+	ms, _ := reg.Gather()
+	return ms
+}
+
+func TestPayloadLimits(t *testing.T) {
+	ms := newLimitsTestMetricSet()
+	encoder := &jsonEncoding{}
+
+	unlimited := &payloadLimits{}
+	data, dropped, err := unlimited.encode(encoder, &Payload{Version: PayloadV2, Stats: ms})
+	if err != nil || dropped != 0 {
+		t.Fatalf("unexpected result without limits: %d, %v", dropped, err)
+	}
+	if bytes.Contains(data, []byte(payloadTruncatedDesc.Name)) {
+		t.Error("unexpected truncation marker without limits")
+	}
+
+	limits := &payloadLimits{
+		maxPayloadSize: 4096,
+		maxMetrics:     40,
+		maxValueLength: 11,
+	}
+	var first []byte
+	for i := 0; i < 3; i++ {
+		data, dropped, err = limits.encode(encoder, &Payload{Version: PayloadV2, Stats: ms})
+		if err != nil {
+			t.Fatalf("failed to encode: %v", err)
+		}
+		if first == nil {
+			first = data
+		} else if !bytes.Equal(first, data) {
+			t.Fatal("truncated payload is not deterministic")
+		}
+	}
+	if len(data) > limits.maxPayloadSize {
+		t.Errorf("payload exceeds size limit: %d", len(data))
+	}
+	if err := ValidateJSON(data); err != nil {
+		t.Errorf("truncated payload is invalid: %v", err)
+	}
+
+	payload, err := DecodePayload(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	stats := payload.Stats
+	if len(stats.Content)-1 != len(ms.Content)-dropped {
+		t.Errorf("unexpected number of metrics: %d, dropped %d", len(stats.Content), dropped)
+	}
+	for _, name := range []string{"program_name", "program_version", "server_guid"} {
+		if stats.Get(name) == nil {
+			t.Errorf("essential metric %v was dropped", name)
+		}
+	}
+	if md := stats.Get("long"); md == nil || md.Value() != strings.Repeat("ä", 5) {
+		t.Errorf("unexpected truncated value: %v", md)
+	}
+	if stats.Get("series_00") == nil || stats.Get("usercnt_active") != nil {
+		t.Errorf("metrics were not dropped in name order: %v", stats.Names())
+	}
+
+	marker := stats.Get(payloadTruncatedDesc.Name)
+	if marker == nil {
+		t.Fatal("no truncation marker")
+	}
+	if marker.Value() != int64(dropped) {
+		t.Errorf("unexpected marker value: %v", marker.Value())
+	}
+	if truncated := marker.Fields["truncated"]; !reflect.DeepEqual(truncated, []interface{}{"long"}) {
+		t.Errorf("unexpected truncated names: %v", truncated)
+	}
+	if names := marker.Fields["dropped"].([]interface{}); len(names) != dropped || names[len(names)-1] != "usercnt_active" {
+		t.Errorf("unexpected dropped names: %v", names)
+	}
+
+	tiny := &payloadLimits{maxPayloadSize: 10}
+	if _, _, err := tiny.encode(encoder, &Payload{Version: PayloadV2, Stats: ms}); err == nil {
+		t.Error("expected error for unreachable size limit")
+	}
+}
+
+func TestPayloadLimitsMaxMetrics(t *testing.T) {
+	ms := newLimitsTestMetricSet()
+	encoder := &jsonEncoding{}
+
+	for _, maxMetrics := range []int{1, 5, len(ms.Content) - 1} {
+		limits := &payloadLimits{maxMetrics: maxMetrics}
+		data, dropped, err := limits.encode(encoder, &Payload{Version: PayloadV2, Stats: ms})
+		if err != nil {
+			t.Fatalf("failed to encode with %d metrics: %v", maxMetrics, err)
+		}
+		payload, err := DecodePayload(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("failed to decode with %d metrics: %v", maxMetrics, err)
+		}
+		if len(payload.Stats.Content) != maxMetrics || dropped != len(ms.Content)-maxMetrics+1 {
+			t.Errorf("unexpected number of metrics with %d metrics: %d, dropped %d", maxMetrics, len(payload.Stats.Content), dropped)
+		}
+		if payload.Stats.Get(payloadTruncatedDesc.Name) == nil {
+			t.Errorf("no truncation marker with %d metrics", maxMetrics)
+		}
+	}
+}
@@ -16,16 +16,178 @@
 
 package ksurveyclient
 
-type logger interface {
+import (
+	"fmt"
+	"strings"
+)
+
+// Logger is the interface used by this library to log structured events. The
+// provided keysAndValues are alternating keys and values, where the keys are
+// strings.
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+// PrintfLogger is the interface implemented by simple loggers like the
+// log.Logger of the standard library.
+type PrintfLogger interface {
 	Printf(string, ...interface{})
 }
 
+// LogrusLogger is the interface implemented by leveled loggers with formatting
+// functions, like logrus.Logger and logrus.Entry.
+type LogrusLogger interface {
+	Debugf(string, ...interface{})
+	Infof(string, ...interface{})
+	Warnf(string, ...interface{})
+	Errorf(string, ...interface{})
+}
+
+// ZapSugaredLogger is the interface implemented by leveled loggers with key
+// value functions, like zap.SugaredLogger.
+type ZapSugaredLogger interface {
+	Debugw(string, ...interface{})
+	Infow(string, ...interface{})
+	Warnw(string, ...interface{})
+	Errorw(string, ...interface{})
+}
+
 type noopLogger struct {
 }
 
-func (log *noopLogger) Printf(string, ...interface{}) {
+func (log *noopLogger) Debug(string, ...interface{}) {
+}
+
+func (log *noopLogger) Info(string, ...interface{}) {
+}
+
+func (log *noopLogger) Warn(string, ...interface{}) {
+}
+
+func (log *noopLogger) Error(string, ...interface{}) {
+}
+
+type stdLogger struct {
+	logger PrintfLogger
+}
+
+// NewStdLogger creates a Logger which writes to the provided PrintfLogger,
+// for example a log.Logger from the standard library. The level and the key
+// value fields are formatted into each line.
+func NewStdLogger(logger PrintfLogger) Logger {
+	return &stdLogger{
+		logger: logger,
+	}
+}
+
+func (log *stdLogger) Debug(msg string, keysAndValues ...interface{}) {
+	log.logger.Printf("%s", formatLogLine("debug", msg, keysAndValues))
+}
+
+func (log *stdLogger) Info(msg string, keysAndValues ...interface{}) {
+	log.logger.Printf("%s", formatLogLine("info", msg, keysAndValues))
+}
+
+func (log *stdLogger) Warn(msg string, keysAndValues ...interface{}) {
+	log.logger.Printf("%s", formatLogLine("warn", msg, keysAndValues))
+}
+
+func (log *stdLogger) Error(msg string, keysAndValues ...interface{}) {
+	log.logger.Printf("%s", formatLogLine("error", msg, keysAndValues))
+}
+
+type logrusLogger struct {
+	logger LogrusLogger
+}
+
+// NewLogrusLogger creates a Logger which writes to the provided LogrusLogger
+// using its levels. The key value fields are appended to the message.
+func NewLogrusLogger(logger LogrusLogger) Logger {
+	return &logrusLogger{
+		logger: logger,
+	}
+}
+
+func (log *logrusLogger) Debug(msg string, keysAndValues ...interface{}) {
+	log.logger.Debugf("%s", formatLogLine("", msg, keysAndValues))
+}
+
+func (log *logrusLogger) Info(msg string, keysAndValues ...interface{}) {
+	log.logger.Infof("%s", formatLogLine("", msg, keysAndValues))
+}
+
+func (log *logrusLogger) Warn(msg string, keysAndValues ...interface{}) {
+	log.logger.Warnf("%s", formatLogLine("", msg, keysAndValues))
+}
+
+func (log *logrusLogger) Error(msg string, keysAndValues ...interface{}) {
+	log.logger.Errorf("%s", formatLogLine("", msg, keysAndValues))
+}
+
+type zapLogger struct {
+	logger ZapSugaredLogger
+}
+
+// NewZapLogger creates a Logger which writes to the provided ZapSugaredLogger,
+// passing the key value fields through unchanged.
+func NewZapLogger(logger ZapSugaredLogger) Logger {
+	return &zapLogger{
+		logger: logger,
+	}
+}
+
+func (log *zapLogger) Debug(msg string, keysAndValues ...interface{}) {
+	log.logger.Debugw(msg, keysAndValues...)
+}
+
+func (log *zapLogger) Info(msg string, keysAndValues ...interface{}) {
+	log.logger.Infow(msg, keysAndValues...)
+}
+
+func (log *zapLogger) Warn(msg string, keysAndValues ...interface{}) {
+	log.logger.Warnw(msg, keysAndValues...)
+}
+
+func (log *zapLogger) Error(msg string, keysAndValues ...interface{}) {
+	log.logger.Errorw(msg, keysAndValues...)
+}
+
+func formatLogLine(level, msg string, keysAndValues []interface{}) string {
+	var b strings.Builder
+	if level != "" {
+		b.WriteString("level=")
+		b.WriteString(level)
+		b.WriteString(" msg=")
+		b.WriteString(fmt.Sprintf("%q", msg))
+	} else {
+		b.WriteString(msg)
+	}
+	for i := 0; i < len(keysAndValues); i += 2 {
+		b.WriteString(" ")
+		b.WriteString(fmt.Sprint(keysAndValues[i]))
+		b.WriteString("=")
+		if i+1 < len(keysAndValues) {
+			b.WriteString(formatLogValue(keysAndValues[i+1]))
+		} else {
+			b.WriteString("(MISSING)")
+		}
+	}
+	return b.String()
+}
+
+// formatLogValue formats the provided value, quoting it if it is empty or
+// contains spaces, quotes or equal signs so the line stays parseable.
+func formatLogValue(value interface{}) string {
+	s := fmt.Sprintf("%v", value)
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
 }
 
 // DefaultLogger is the logger used by this library if no other is explicitly
 // specified.
-var DefaultLogger logger = &noopLogger{}
+var DefaultLogger Logger = &noopLogger{}
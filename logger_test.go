@@ -17,13 +17,111 @@
 package ksurveyclient
 
 import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
 	"testing"
 )
 
 type testingLogger struct {
 	T *testing.T
+
+	mutex  sync.Mutex
+	closed bool
 }
 
 func (logger *testingLogger) Printf(format string, args ...interface{}) {
-	logger.T.Logf(format, args...)
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+	if !logger.closed {
+		logger.T.Logf(format, args...)
+	}
+}
+
+// Close stops logging, so the logger can be used by goroutines which might
+// outlive the test.
+func (logger *testingLogger) Close() {
+	logger.mutex.Lock()
+	logger.closed = true
+	logger.mutex.Unlock()
+}
+
+type recordingLogger struct {
+	lines []string
+}
+
+func (logger *recordingLogger) record(level, format string, args ...interface{}) {
+	logger.lines = append(logger.lines, level+": "+fmt.Sprintf(format, args...))
+}
+
+func (logger *recordingLogger) Debugf(format string, args ...interface{}) {
+	logger.record("debug", format, args...)
+}
+
+func (logger *recordingLogger) Infof(format string, args ...interface{}) {
+	logger.record("info", format, args...)
+}
+
+func (logger *recordingLogger) Warnf(format string, args ...interface{}) {
+	logger.record("warn", format, args...)
+}
+
+func (logger *recordingLogger) Errorf(format string, args ...interface{}) {
+	logger.record("error", format, args...)
+}
+
+func (logger *recordingLogger) Debugw(msg string, keysAndValues ...interface{}) {
+	logger.record("debug", "%s %v", msg, keysAndValues)
+}
+
+func (logger *recordingLogger) Infow(msg string, keysAndValues ...interface{}) {
+	logger.record("info", "%s %v", msg, keysAndValues)
+}
+
+func (logger *recordingLogger) Warnw(msg string, keysAndValues ...interface{}) {
+	logger.record("warn", "%s %v", msg, keysAndValues)
+}
+
+func (logger *recordingLogger) Errorw(msg string, keysAndValues ...interface{}) {
+	logger.record("error", "%s %v", msg, keysAndValues)
+}
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewStdLogger(log.New(&buf, "", 0))
+	logger.Warn("survey submission failed", append(errorFields(&StatusError{StatusCode: 500}), "url", "", "dangling")...)
+
+	expected := "level=warn msg=\"survey submission failed\" error=\"unexpected response status: 500\" status_code=500 url=\"\" dangling=(MISSING)\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected log output: %q", buf.String())
+	}
+}
+
+func TestLogrusLogger(t *testing.T) {
+	rl := &recordingLogger{}
+	logger := NewLogrusLogger(rl)
+	logger.Debug("survey submitted", "status_code", 200)
+	logger.Error("boom")
+
+	if len(rl.lines) != 2 {
+		t.Fatalf("unexpected number of log lines: %d", len(rl.lines))
+	}
+	if rl.lines[0] != "debug: survey submitted status_code=200" {
+		t.Errorf("unexpected log line: %q", rl.lines[0])
+	}
+	if !strings.HasPrefix(rl.lines[1], "error: boom") {
+		t.Errorf("unexpected log line: %q", rl.lines[1])
+	}
+}
+
+func TestZapLogger(t *testing.T) {
+	rl := &recordingLogger{}
+	logger := NewZapLogger(rl)
+	logger.Info("survey submitted", "status_code", 200)
+
+	if len(rl.lines) != 1 || rl.lines[0] != "info: survey submitted [status_code 200]" {
+		t.Errorf("unexpected log lines: %v", rl.lines)
+	}
 }
@@ -17,22 +17,242 @@
 package ksurveyclient
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
 )
 
-// A MetricSet holds the collected MetricData.
+// A MetricSet holds the collected MetricData, sorted by name.
 type MetricSet struct {
 	Content []*MetricData
 }
 
-// MarshalJSON serializes the associated MetricSet collected data to JSON.
+// NewMetricSet creates a MetricSet with the provided MetricData, sorting it by
+// name. MetricData with the same name keep their order.
+func NewMetricSet(content []*MetricData) *MetricSet {
+	ms := &MetricSet{
+		Content: content,
+	}
+	ms.Sort()
+	return ms
+}
+
+// Sort sorts the Content of the associated MetricSet by name.
+func (ms *MetricSet) Sort() {
+	sort.SliceStable(ms.Content, func(i, j int) bool {
+		return ms.Content[i].Name < ms.Content[j].Name
+	})
+}
+
+// Get returns the MetricData with the provided name or nil if the associated
+// MetricSet has no such MetricData.
+func (ms *MetricSet) Get(name string) *MetricData {
+	idx := sort.Search(len(ms.Content), func(i int) bool {
+		return ms.Content[i].Name >= name
+	})
+	if idx < len(ms.Content) && ms.Content[idx].Name == name {
+		return ms.Content[idx]
+	}
+	// Content might not be sorted, if set directly.
+	for _, md := range ms.Content {
+		if md.Name == name {
+			return md
+		}
+	}
+	return nil
+}
+
+// Names returns the names of the MetricData in the associated MetricSet in
+// order.
+func (ms *MetricSet) Names() []string {
+	names := make([]string, len(ms.Content))
+	for idx, md := range ms.Content {
+		names[idx] = md.Name
+	}
+	return names
+}
+
+// MarshalJSON serializes the associated MetricSet collected data to JSON. The
+// output is deterministic, with the metrics sorted by name. If names are not
+// unique, the first MetricData with a name wins, like when gathering.
 func (ms *MetricSet) MarshalJSON() ([]byte, error) {
-	cs := make(map[string]interface{})
-	for _, c := range ms.Content {
-		cs[c.Name] = c.Fields
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for idx, md := range ms.unique() {
+		if idx > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(md.Name)
+		if err != nil {
+			return nil, err
+		}
+		fields, err := json.Marshal(md.Fields)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(fields)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// unique returns the Content of the associated MetricSet sorted by name with
+// only the first MetricData of each name.
+func (ms *MetricSet) unique() []*MetricData {
+	content := make([]*MetricData, 0, len(ms.Content))
+	seen := make(map[string]bool)
+	for _, md := range ms.Content {
+		if seen[md.Name] {
+			continue
+		}
+		seen[md.Name] = true
+		content = append(content, md)
+	}
+	sort.SliceStable(content, func(i, j int) bool {
+		return content[i].Name < content[j].Name
+	})
+	return content
+}
+
+// UnmarshalJSON deserializes the provided JSON data into the associated
+// MetricSet, sorted by name. Numeric values, including the labeled values of
+// vectors, are restored as int64 or float64 according to the declared type.
+func (ms *MetricSet) UnmarshalJSON(data []byte) error {
+	var raw map[string]map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+	return ms.setFields(raw)
+}
+
+// setFields replaces the content of the associated MetricSet with metrics
+// created from the provided decoded fields by name.
+func (ms *MetricSet) setFields(raw map[string]map[string]interface{}) error {
+	content := make([]*MetricData, 0, len(raw))
+	for name, fields := range raw {
+		if fields == nil {
+			return fmt.Errorf("metric %q: no fields", name)
+		}
+		valueType, _ := fields["type"].(string)
+		for key, value := range fields {
+			switch key {
+			case "value":
+				converted, err := convertValue(ValueType(valueType), value)
+				if err != nil {
+					return fmt.Errorf("metric %q: %v", name, err)
+				}
+				fields[key] = converted
+			case "values":
+				converted, err := convertValues(ValueType(valueType), value)
+				if err != nil {
+					return fmt.Errorf("metric %q: %v", name, err)
+				}
+				fields[key] = converted
+			default:
+				fields[key] = normalizeValue(value)
+			}
+		}
+		content = append(content, &MetricData{
+			Name:   name,
+			Fields: fields,
+		})
+	}
+
+	*ms = *NewMetricSet(content)
+	return nil
+}
+
+// convertValue converts the provided decoded numeric value according to the
+// provided ValueType.
+func convertValue(valueType ValueType, value interface{}) (interface{}, error) {
+	switch valueType {
+	case IntValue:
+		switch v := value.(type) {
+		case json.Number:
+			return v.Int64()
+		case uint64:
+			if v > math.MaxInt64 {
+				return nil, fmt.Errorf("int value out of range: %d", v)
+			}
+			return int64(v), nil
+		case float64:
+			return nil, fmt.Errorf("invalid int value: %v", v)
+		}
+	case FloatValue:
+		switch v := value.(type) {
+		case json.Number:
+			return v.Float64()
+		case uint64:
+			return float64(v), nil
+		case int64:
+			return float64(v), nil
+		}
 	}
+	return normalizeValue(value), nil
+}
 
-	return json.Marshal(cs)
+// convertValues converts the values of the provided decoded labeled values of
+// a vector according to the provided ValueType.
+func convertValues(valueType ValueType, values interface{}) (interface{}, error) {
+	normalized := normalizeValue(values)
+	list, ok := normalized.([]interface{})
+	if !ok {
+		return normalized, nil
+	}
+	for _, item := range list {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if value, ok := entry["value"]; ok {
+			converted, err := convertValue(valueType, value)
+			if err != nil {
+				return nil, err
+			}
+			entry["value"] = converted
+		}
+	}
+	return list, nil
+}
+
+// normalizeValue replaces decoded numbers in the provided value with int64 if
+// possible and float64 otherwise, and decoded maps with map[string]interface{}.
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case uint64:
+		if v <= math.MaxInt64 {
+			return int64(v)
+		}
+		return float64(v)
+	case []interface{}:
+		for idx := range v {
+			v[idx] = normalizeValue(v[idx])
+		}
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = normalizeValue(v[key])
+		}
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = normalizeValue(value)
+		}
+		return m
+	}
+	return value
 }
 
 // MetricData holds the collected data with its name and fields.
@@ -41,6 +261,34 @@ type MetricData struct {
 	Fields map[string]interface{}
 }
 
+// Help returns the help text of the associated MetricData.
+func (md *MetricData) Help() string {
+	help, _ := md.Fields["desc"].(string)
+	return help
+}
+
+// ValueType returns the ValueType of the associated MetricData.
+func (md *MetricData) ValueType() ValueType {
+	valueType, _ := md.Fields["type"].(string)
+	return ValueType(valueType)
+}
+
+// Mode returns the Mode of the associated MetricData.
+func (md *MetricData) Mode() Mode {
+	mode, _ := md.Fields["mode"].(string)
+	return Mode(mode)
+}
+
+// Value returns the value of the associated MetricData.
+func (md *MetricData) Value() interface{} {
+	return md.Fields["value"]
+}
+
+// Desc returns a Desc describing the associated MetricData.
+func (md *MetricData) Desc() *Desc {
+	return NewDesc(md.Name, md.Help(), md.ValueType(), md.Mode())
+}
+
 // Metric is the interface implemented by anything that can be used to provide
 // survey Metrics.
 type Metric interface {
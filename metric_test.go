@@ -0,0 +1,121 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func newTestRegistry() *Registry {
+	reg := NewRegistry()
+	reg.MustRegister(
+		NewProgramCollector("kopano-test", "1.2.3", testGUID),
+		MustNewConstInt(testUsercntDesc, 42),
+		MustNewConstFloat(NewDesc("ratio", "Some ratio", FloatValue, GaugeMode), 0.5),
+		MustNewConstBool(NewDesc("enabled", "", BoolValue, InfoMode), true),
+	)
+	return reg
+}
+
+func TestMetricSetDeterministic(t *testing.T) {
+	var first []byte
+	for i := 0; i < 10; i++ {
+		ms, err := newTestRegistry().Gather()
+		if err != nil {
+			t.Fatalf("failed to gather: %v", err)
+		}
+		expected := []string{"enabled", "program_name", "program_version", "ratio", "server_guid", "usercnt_active"}
+		if names := ms.Names(); !reflect.DeepEqual(names, expected) {
+			t.Fatalf("unexpected names: %v", names)
+		}
+		b, err := json.Marshal(ms)
+		if err != nil {
+			t.Fatalf("failed to encode: %v", err)
+		}
+		if first == nil {
+			first = b
+		} else if !bytes.Equal(first, b) {
+			t.Fatalf("output differs between runs:\n%s\n%s", first, b)
+		}
+	}
+}
+
+func TestMetricSetGet(t *testing.T) {
+	ms, _ := newTestRegistry().Gather()
+	if md := ms.Get("usercnt_active"); md == nil || md.Value() != int64(42) {
+		t.Errorf("unexpected metric data: %v", md)
+	}
+	if md := ms.Get("unknown"); md != nil {
+		t.Errorf("unexpected metric data for unknown name: %v", md)
+	}
+
+	unsorted := &MetricSet{
+		Content: []*MetricData{{Name: "b"}, {Name: "a"}},
+	}
+	if md := unsorted.Get("b"); md == nil || md.Name != "b" {
+		t.Errorf("unexpected metric data from unsorted set: %v", md)
+	}
+}
+
+func TestMetricSetDuplicates(t *testing.T) {
+	ms := &MetricSet{
+		Content: []*MetricData{
+			{Name: "a", Fields: map[string]interface{}{"type": "int", "value": 1}},
+			{Name: "a", Fields: map[string]interface{}{"type": "int", "value": 2}},
+		},
+	}
+	b, err := json.Marshal(ms)
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+	if expected := `{"a":{"type":"int","value":1}}`; string(b) != expected {
+		t.Errorf("unexpected output for duplicates: %s", b)
+	}
+}
+
+func TestMetricSetGolden(t *testing.T) {
+	ms, err := newTestRegistry().Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+	b, err := json.MarshalIndent(ms, "", "  ")
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+
+	golden := filepath.Join("testdata", "metricset.golden.json")
+	if *update {
+		if err := ioutil.WriteFile(golden, b, 0644); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+	}
+	expected, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("failed to read golden file: %v", err)
+	}
+	if !bytes.Equal(b, expected) {
+		t.Errorf("output differs from golden file:\n%s", b)
+	}
+}
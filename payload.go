@@ -16,7 +16,179 @@
 
 package ksurveyclient
 
-type kSurveyPayloadV2 struct {
-	Version int        `json:"version"`
-	Stats   *MetricSet `json:"stats"`
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// Supported payload versions.
+const (
+	PayloadV2 = 2
+	PayloadV3 = 3
+)
+
+// LibraryVersion is the version of this library as reported in payloads.
+const LibraryVersion = "1.0"
+
+// SchemaVersion is the version of the metric schema used in the stats of
+// payloads.
+const SchemaVersion = 1
+
+// Consent levels for the Envelope of a Payload.
+const (
+	ConsentUnspecified = ""
+	ConsentBasic       = "basic"
+	ConsentFull        = "full"
+)
+
+// A Payload is the data submitted by survey clients. The Envelope is only
+// included with PayloadV3 and later.
+type Payload struct {
+	Version  int        `json:"version"`
+	Envelope *Envelope  `json:"envelope,omitempty"`
+	Stats    *MetricSet `json:"stats"`
+}
+
+// An Envelope holds the metadata of a Payload.
+type Envelope struct {
+	LibraryVersion string `json:"library_version"`
+	Product        string `json:"product,omitempty"`
+	ProductVersion string `json:"product_version,omitempty"`
+	InstallationID string `json:"installation_id,omitempty"`
+	Sequence       uint64 `json:"sequence"`
+	Timestamp      int64  `json:"timestamp"`
+	TZOffset       int    `json:"tz_offset"`
+	ConsentLevel   string `json:"consent_level,omitempty"`
+	SchemaVersion  int    `json:"schema_version"`
+}
+
+// NewEnvelope creates an Envelope for the provided MetricSet gathered at the
+// provided time. Product information and installation ID are taken from the
+// metrics of the program collector if present.
+func NewEnvelope(ms *MetricSet, gathered time.Time, sequence uint64, consentLevel string) *Envelope {
+	_, offset := gathered.Zone()
+	e := &Envelope{
+		LibraryVersion: LibraryVersion,
+		Sequence:       sequence,
+		Timestamp:      gathered.Unix(),
+		TZOffset:       offset,
+		ConsentLevel:   consentLevel,
+		SchemaVersion:  SchemaVersion,
+	}
+	if ms != nil {
+		e.Product = stringValue(ms, programNameDesc.Name)
+		e.ProductVersion = stringValue(ms, programVersionDesc.Name)
+		e.InstallationID = stringValue(ms, serverGUIDDesc.Name)
+	}
+	return e
+}
+
+func stringValue(ms *MetricSet, name string) string {
+	if md := ms.Get(name); md != nil {
+		if s, ok := md.Value().(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// EncodePayload writes the JSON encoding of the provided Payload to the
+// provided Writer.
+func EncodePayload(w io.Writer, payload *Payload) error {
+	payload, err := checkPayload(payload)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(payload)
+}
+
+// DecodePayload reads a JSON encoded Payload of any supported version from the
+// provided Reader.
+func DecodePayload(r io.Reader) (*Payload, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := DetectPayloadVersion(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPayloadVersion(version); err != nil {
+		return nil, err
+	}
+
+	payload := &Payload{}
+	if err := json.Unmarshal(data, payload); err != nil {
+		return nil, err
+	}
+	return checkDecodedPayload(payload)
+}
+
+// checkPayload validates the provided Payload for encoding. The returned
+// Payload omits all data which is not supported by its version.
+func checkPayload(payload *Payload) (*Payload, error) {
+	switch payload.Version {
+	case PayloadV2:
+		if payload.Envelope != nil {
+			// NOTE: V2 has no envelope, drop it.
+			v2 := *payload
+			v2.Envelope = nil
+			payload = &v2
+		}
+	case PayloadV3:
+		if payload.Envelope == nil {
+			return nil, errors.New("payload without envelope")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported payload version: %d", payload.Version)
+	}
+	return payload, nil
+}
+
+func checkPayloadVersion(version int) error {
+	switch version {
+	case PayloadV2, PayloadV3:
+		return nil
+	default:
+		return fmt.Errorf("unsupported payload version: %d", version)
+	}
+}
+
+// checkDecodedPayload validates the provided decoded Payload.
+func checkDecodedPayload(payload *Payload) (*Payload, error) {
+	if payload.Stats == nil {
+		return nil, errors.New("payload without stats")
+	}
+	switch {
+	case payload.Version == PayloadV2:
+		payload.Envelope = nil
+	case payload.Envelope == nil:
+		return nil, errors.New("payload without envelope")
+	}
+	return payload, nil
+}
+
+// DetectPayloadVersion returns the version of the provided JSON encoded
+// Payload.
+func DetectPayloadVersion(data []byte) (int, error) {
+	var header struct {
+		Version *int `json:"version"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&header); err != nil {
+		return 0, fmt.Errorf("invalid payload: %v", err)
+	}
+	if header.Version == nil {
+		return 0, errors.New("invalid payload: no version")
+	}
+	return *header.Version, nil
 }
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPayloadRoundTrip(t *testing.T) {
+	ms, err := newTestRegistry().Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := EncodePayload(&buf, &Payload{Version: PayloadV2, Stats: ms}); err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+	if version, err := DetectPayloadVersion(buf.Bytes()); err != nil || version != PayloadV2 {
+		t.Fatalf("unexpected version: %d, %v", version, err)
+	}
+
+	payload, err := DecodePayload(&buf)
+	if err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if payload.Version != PayloadV2 {
+		t.Errorf("unexpected version: %d", payload.Version)
+	}
+	if !reflect.DeepEqual(payload.Stats, ms) {
+		t.Errorf("decoded stats differ:\n%v\n%v", payload.Stats, ms)
+	}
+	if v := payload.Stats.Get("usercnt_active").Value(); v != int64(42) {
+		t.Errorf("unexpected int value: %#v", v)
+	}
+	if v := payload.Stats.Get("ratio").Value(); v != 0.5 {
+		t.Errorf("unexpected float value: %#v", v)
+	}
+}
+
+func TestDecodePayloadErrors(t *testing.T) {
+	for _, raw := range []string{
+		``,
+		`{}`,
+		`{"version": 1, "stats": {}}`,
+		`{"version": 2}`,
+		`{"version": 2, "stats": {"a": {"type": "int", "value": 1.5}}}`,
+	} {
+		if _, err := DecodePayload(strings.NewReader(raw)); err == nil {
+			t.Errorf("expected error for %q", raw)
+		}
+	}
+}
+
+func TestPayloadV3(t *testing.T) {
+	ms, err := newTestRegistry().Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+	gathered := time.Date(2019, 7, 1, 12, 0, 0, 0, time.FixedZone("CEST", 7200))
+	envelope := NewEnvelope(ms, gathered, 3, ConsentBasic)
+
+	expected := &Envelope{
+		LibraryVersion: LibraryVersion,
+		Product:        "kopano-test",
+		ProductVersion: "1.2.3",
+		InstallationID: string(testGUID),
+		Sequence:       3,
+		Timestamp:      gathered.Unix(),
+		TZOffset:       7200,
+		ConsentLevel:   ConsentBasic,
+		SchemaVersion:  SchemaVersion,
+	}
+	if !reflect.DeepEqual(envelope, expected) {
+		t.Fatalf("unexpected envelope: %+v", envelope)
+	}
+
+	var buf bytes.Buffer
+	if err := EncodePayload(&buf, &Payload{Version: PayloadV3, Stats: ms}); err == nil {
+		t.Error("expected error for V3 payload without envelope")
+	}
+	buf.Reset()
+	if err := EncodePayload(&buf, &Payload{Version: PayloadV3, Envelope: envelope, Stats: ms}); err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+	payload, err := DecodePayload(&buf)
+	if err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if payload.Version != PayloadV3 || !reflect.DeepEqual(payload.Envelope, expected) {
+		t.Errorf("unexpected payload: %d %+v", payload.Version, payload.Envelope)
+	}
+
+	buf.Reset()
+	if err := EncodePayload(&buf, &Payload{Version: PayloadV2, Envelope: envelope, Stats: ms}); err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+	if strings.Contains(buf.String(), "envelope") {
+		t.Errorf("V2 payload contains envelope: %s", buf.String())
+	}
+}
@@ -29,6 +29,12 @@ var (
 	DefaultProgramGUID    = []byte("")
 )
 
+var (
+	programNameDesc    = NewDesc("program_name", "Program name", StringValue, NoMode)
+	programVersionDesc = NewDesc("program_version", "Program version", StringValue, NoMode)
+	serverGUIDDesc     = NewDesc("server_guid", "", StringValue, NoMode)
+)
+
 type programCollector struct {
 	name    string
 	version string
@@ -48,6 +54,14 @@ func NewProgramCollector(name string, version string, guid []byte) Collector {
 	}
 }
 
+// Describe sends the descriptions of the metrics collected by the associated
+// programCollector.
+func (pc *programCollector) Describe(ch chan<- *Desc) {
+	ch <- programNameDesc
+	ch <- programVersionDesc
+	ch <- serverGUIDDesc
+}
+
 // Collect first gathers the associated managers collectors managers data. Then
 // it creates constant metrics based on the returned data.
 func (pc *programCollector) Collect(ch chan<- Metric) {
@@ -56,11 +70,7 @@ func (pc *programCollector) Collect(ch chan<- Metric) {
 		if pc.name == "" {
 			name = DefaultProgramName
 		}
-		ch <- MustNewConstMap("program_name", map[string]interface{}{
-			"desc":  "Program name",
-			"type":  "string",
-			"value": name,
-		})
+		ch <- MustNewConstString(programNameDesc, name)
 	}()
 
 	func() {
@@ -68,11 +78,7 @@ func (pc *programCollector) Collect(ch chan<- Metric) {
 		if version == "" {
 			version = DefaultProgramVersion
 		}
-		ch <- MustNewConstMap("program_version", map[string]interface{}{
-			"desc":  "Program version",
-			"type":  "string",
-			"value": version,
-		})
+		ch <- MustNewConstString(programVersionDesc, version)
 	}()
 
 	func() {
@@ -80,10 +86,6 @@ func (pc *programCollector) Collect(ch chan<- Metric) {
 		if guid == nil {
 			guid = DefaultProgramGUID
 		}
-		ch <- MustNewConstMap("server_guid", map[string]interface{}{
-			"desc":  "",
-			"type":  "string",
-			"value": string(guid),
-		})
+		ch <- MustNewConstString(serverGUIDDesc, string(guid))
 	}()
 }
@@ -0,0 +1,113 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"stash.kopano.io/kgol/ksurveyclient-go"
+)
+
+type clientMetricsCollector struct {
+	metrics *ksurveyclient.ClientMetrics
+
+	submissionsAttemptedDesc *prometheus.Desc
+	submissionsSucceededDesc *prometheus.Desc
+	submissionsFailedDesc    *prometheus.Desc
+	lastSuccessDesc          *prometheus.Desc
+	payloadBytesDesc         *prometheus.Desc
+	payloadBytesTotalDesc    *prometheus.Desc
+	gatherDurationDesc       *prometheus.Desc
+}
+
+// NewClientMetricsCollector creates a prometheus.Collector which exposes the
+// provided ksurveyclient.ClientMetrics. If metrics is nil, the
+// ksurveyclient.DefaultClientMetrics are used.
+func NewClientMetricsCollector(metrics *ksurveyclient.ClientMetrics) prometheus.Collector {
+	if metrics == nil {
+		metrics = ksurveyclient.DefaultClientMetrics
+	}
+
+	return &clientMetricsCollector{
+		metrics: metrics,
+
+		submissionsAttemptedDesc: prometheus.NewDesc(
+			"ksurveyclient_submissions_attempted_total",
+			"Total number of survey submissions attempted",
+			nil, nil,
+		),
+		submissionsSucceededDesc: prometheus.NewDesc(
+			"ksurveyclient_submissions_succeeded_total",
+			"Total number of survey submissions succeeded",
+			nil, nil,
+		),
+		submissionsFailedDesc: prometheus.NewDesc(
+			"ksurveyclient_submissions_failed_total",
+			"Total number of survey submissions failed",
+			nil, nil,
+		),
+		lastSuccessDesc: prometheus.NewDesc(
+			"ksurveyclient_last_success_timestamp_seconds",
+			"Unix time of the last successful survey submission",
+			nil, nil,
+		),
+		payloadBytesDesc: prometheus.NewDesc(
+			"ksurveyclient_payload_bytes",
+			"Size of the last survey payload in bytes",
+			nil, nil,
+		),
+		payloadBytesTotalDesc: prometheus.NewDesc(
+			"ksurveyclient_payload_bytes_total",
+			"Total size of all survey payloads in bytes",
+			nil, nil,
+		),
+		gatherDurationDesc: prometheus.NewDesc(
+			"ksurveyclient_gather_duration_seconds",
+			"Duration of the last gather in seconds",
+			nil, nil,
+		),
+	}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c *clientMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.submissionsAttemptedDesc
+	ch <- c.submissionsSucceededDesc
+	ch <- c.submissionsFailedDesc
+	ch <- c.lastSuccessDesc
+	ch <- c.payloadBytesDesc
+	ch <- c.payloadBytesTotalDesc
+	ch <- c.gatherDurationDesc
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *clientMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.metrics.Snapshot()
+
+	var lastSuccess float64
+	if !s.LastSuccess.IsZero() {
+		lastSuccess = float64(s.LastSuccess.Unix())
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.submissionsAttemptedDesc, prometheus.CounterValue, float64(s.SubmissionsAttempted))
+	ch <- prometheus.MustNewConstMetric(c.submissionsSucceededDesc, prometheus.CounterValue, float64(s.SubmissionsSucceeded))
+	ch <- prometheus.MustNewConstMetric(c.submissionsFailedDesc, prometheus.CounterValue, float64(s.SubmissionsFailed))
+	ch <- prometheus.MustNewConstMetric(c.lastSuccessDesc, prometheus.GaugeValue, lastSuccess)
+	ch <- prometheus.MustNewConstMetric(c.payloadBytesDesc, prometheus.GaugeValue, float64(s.PayloadBytes))
+	ch <- prometheus.MustNewConstMetric(c.payloadBytesTotalDesc, prometheus.CounterValue, float64(s.PayloadBytesTotal))
+	ch <- prometheus.MustNewConstMetric(c.gatherDurationDesc, prometheus.GaugeValue, s.GatherDuration.Seconds())
+}
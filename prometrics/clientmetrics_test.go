@@ -0,0 +1,100 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"stash.kopano.io/kgol/ksurveyclient-go"
+)
+
+func TestClientMetricsCollector(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+	}))
+	defer ts.Close()
+
+	metrics := ksurveyclient.NewClientMetrics()
+	config := ksurveyclient.DefaultConfig.Clone()
+	config.URL = ts.URL
+	config.HTTPClient = ts.Client()
+	config.StartDelay = 0
+	config.Metrics = metrics
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := ksurveyclient.StartKSurveyClient(ctx, config, ksurveyclient.NewRegistry()); err != nil {
+		t.Fatalf("failed to start survey client: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for metrics.Snapshot().SubmissionsSucceeded == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("submission did not succeed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(NewClientMetricsCollector(metrics)); err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+
+	s := metrics.Snapshot()
+	expected := map[string]float64{
+		"ksurveyclient_submissions_attempted_total":    float64(s.SubmissionsAttempted),
+		"ksurveyclient_submissions_succeeded_total":    float64(s.SubmissionsSucceeded),
+		"ksurveyclient_submissions_failed_total":       float64(s.SubmissionsFailed),
+		"ksurveyclient_last_success_timestamp_seconds": float64(s.LastSuccess.Unix()),
+		"ksurveyclient_payload_bytes":                  float64(s.PayloadBytes),
+		"ksurveyclient_payload_bytes_total":            float64(s.PayloadBytesTotal),
+		"ksurveyclient_gather_duration_seconds":        s.GatherDuration.Seconds(),
+	}
+	if len(mfs) != len(expected) {
+		t.Errorf("unexpected number of metrics: %d", len(mfs))
+	}
+	for _, mf := range mfs {
+		value, ok := expected[mf.GetName()]
+		if !ok {
+			t.Errorf("unexpected metric: %s", mf.GetName())
+			continue
+		}
+		m := mf.GetMetric()[0]
+		var got float64
+		if m.GetCounter() != nil {
+			got = m.GetCounter().GetValue()
+		} else {
+			got = m.GetGauge().GetValue()
+		}
+		if got != value {
+			t.Errorf("unexpected value for %s: %v, expected %v", mf.GetName(), got, value)
+		}
+	}
+	if s.SubmissionsSucceeded < 1 || s.PayloadBytes == 0 || s.LastSuccess.IsZero() {
+		t.Errorf("unexpected client metrics: %+v", s)
+	}
+}
@@ -18,8 +18,11 @@ package prometrics
 
 import (
 	"errors"
+	"fmt"
+	"math"
 	"reflect"
 	"strings"
+	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
@@ -66,9 +69,23 @@ func (c *collector) Collect(ch chan<- ksurveyclient.Metric) {
 	}
 }
 
-type proMetrics struct {
-	metric prometheus.Metric
+// Describe sends the descriptions of the whitelisted metrics of the wrapped
+// prometheus.Collector with their aliased names.
+func (c *collector) Describe(ch chan<- *ksurveyclient.Desc) {
+	descs := make(chan *prometheus.Desc)
+	go func() {
+		c.collector.Describe(descs)
+		close(descs)
+	}()
+	for desc := range descs {
+		pd := newProDesc(desc, c.whitelist)
+		if pd != nil && pd.err == nil {
+			ch <- ksurveyclient.NewDesc(pd.fqName, pd.help, ksurveyclient.ValueType(pd.mtype), ksurveyclient.Mode(pd.mmode))
+		}
+	}
+}
 
+type proDesc struct {
 	fqName string
 	help   string
 
@@ -78,10 +95,9 @@ type proMetrics struct {
 	err error
 }
 
-func newProMetrics(metric prometheus.Metric, whitelist map[string]string) *proMetrics {
+func newProDesc(desc *prometheus.Desc, whitelist map[string]string) *proDesc {
 	var err error
 
-	desc := metric.Desc()
 	// NOTE(longsleep): Since all fields in Desc are private we unfortunately
 	// need to use reflect to find the name and description of the prometheus
 	// metrics currectly processed :(.
@@ -118,9 +134,7 @@ func newProMetrics(metric prometheus.Metric, whitelist map[string]string) *proMe
 		err = errors.New("no help field")
 	}
 
-	return &proMetrics{
-		metric: metric,
-
+	return &proDesc{
 		fqName: fqName,
 		help:   help.String(),
 
@@ -131,6 +145,25 @@ func newProMetrics(metric prometheus.Metric, whitelist map[string]string) *proMe
 	}
 }
 
+type proMetrics struct {
+	metric prometheus.Metric
+
+	*proDesc
+}
+
+func newProMetrics(metric prometheus.Metric, whitelist map[string]string) *proMetrics {
+	pd := newProDesc(metric.Desc(), whitelist)
+	if pd == nil {
+		return nil
+	}
+
+	return &proMetrics{
+		metric: metric,
+
+		proDesc: pd,
+	}
+}
+
 func (pm *proMetrics) Write(md *ksurveyclient.MetricData) error {
 	if pm.err != nil {
 		return pm.err
@@ -143,7 +176,7 @@ func (pm *proMetrics) Write(md *ksurveyclient.MetricData) error {
 
 	var mtype string
 	var mmode string
-	var value interface{}
+	var value float64
 
 	// Set defaults from prometheus metrics data.
 	switch {
@@ -167,12 +200,19 @@ func (pm *proMetrics) Write(md *ksurveyclient.MetricData) error {
 		mmode = pm.mmode
 	}
 
-	m, err := ksurveyclient.NewConstMap(pm.fqName, map[string]interface{}{
-		"desc":  pm.help,
-		"mode":  mmode,
-		"type":  mtype,
-		"value": value,
-	})
+	desc := ksurveyclient.NewDesc(pm.fqName, pm.help, ksurveyclient.ValueType(mtype), ksurveyclient.Mode(mmode))
+	var m ksurveyclient.ConstMap
+	var err error
+	switch desc.ValueType {
+	case ksurveyclient.IntValue:
+		// Prometheus values are always floats, convert as requested.
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return fmt.Errorf("metric %q: float value %v is not finite", pm.fqName, value)
+		}
+		m, err = ksurveyclient.NewConstInt(desc, int64(value))
+	default:
+		m, err = ksurveyclient.NewConstFloat(desc, value)
+	}
 	if err != nil {
 		return err
 	}
@@ -183,6 +223,9 @@ func (pm *proMetrics) Write(md *ksurveyclient.MetricData) error {
 type registry struct {
 	registry  *ksurveyclient.Registry
 	whitelist map[string]string
+
+	mutex   sync.Mutex
+	wrapped []*collector
 }
 
 // WrapRegistry wraps the provided ksurveyclient.Registry so it can register
@@ -212,7 +255,22 @@ func WrapRegistry(reg *ksurveyclient.Registry, whitelist map[string]string) prom
 
 // Register registers the provided Collector with the associated Registry.
 func (reg *registry) Register(c prometheus.Collector) error {
-	return reg.registry.Register(WrapCollector(c, reg.whitelist))
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+
+	if reg.indexOf(c) >= 0 {
+		return prometheus.AlreadyRegisteredError{
+			ExistingCollector: c,
+			NewCollector:      c,
+		}
+	}
+	wrapped := WrapCollector(c, reg.whitelist).(*collector)
+	if err := reg.registry.Register(wrapped); err != nil {
+		return err
+	}
+	reg.wrapped = append(reg.wrapped, wrapped)
+
+	return nil
 }
 
 // MustRegister registers the provided Collectors with the accociated Registry
@@ -225,8 +283,31 @@ func (reg *registry) MustRegister(cs ...prometheus.Collector) {
 	}
 }
 
-// Unregister is there to satisfy the prometheus.Registerer interface. It has
-// no effect on this registry.
+// Unregister unregisters the provided Collector from the associated Registry.
+// It returns true if the Collector was registered.
 func (reg *registry) Unregister(c prometheus.Collector) bool {
-	return false
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+
+	idx := reg.indexOf(c)
+	if idx < 0 {
+		return false
+	}
+	wrapped := reg.wrapped[idx]
+	reg.wrapped = append(reg.wrapped[:idx], reg.wrapped[idx+1:]...)
+
+	return reg.registry.Unregister(wrapped)
+}
+
+func (reg *registry) indexOf(c prometheus.Collector) int {
+	t := reflect.TypeOf(c)
+	if t == nil || !t.Comparable() {
+		return -1
+	}
+	for idx, wrapped := range reg.wrapped {
+		if reflect.TypeOf(wrapped.collector) == t && wrapped.collector == c {
+			return idx
+		}
+	}
+	return -1
 }
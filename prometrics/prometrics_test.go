@@ -0,0 +1,102 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometrics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"stash.kopano.io/kgol/ksurveyclient-go"
+)
+
+func TestWrapRegistry(t *testing.T) {
+	reg := ksurveyclient.NewRegistry()
+	wrapper := WrapRegistry(reg, map[string]string{
+		"rtm_distinct_users_connected_max": "usercnt_active,gauge:int",
+	})
+
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "rtm_distinct_users_connected_max",
+		Help: "Maximum number of distinct users connected",
+	})
+	gauge.Set(42)
+	if err := wrapper.Register(gauge); err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+	if err := wrapper.Register(gauge); err == nil {
+		t.Error("expected error when registering the same collector twice")
+	}
+
+	ms, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+	if len(ms.Content) != 1 {
+		t.Fatalf("unexpected number of metrics: %d", len(ms.Content))
+	}
+	md := ms.Content[0]
+	if md.Name != "usercnt_active" || md.Value() != int64(42) || md.Mode() != ksurveyclient.GaugeMode {
+		t.Errorf("unexpected metric: %s %v", md.Name, md.Fields)
+	}
+	if err := ksurveyclient.Validate(&ksurveyclient.Payload{Version: ksurveyclient.PayloadV2, Stats: ms}); err != nil {
+		t.Errorf("invalid payload: %v", err)
+	}
+
+	other := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "rtm_distinct_users_connected_max",
+		Help: "Other gauge aliased to the same name",
+	})
+	if err := wrapper.Register(other); err == nil {
+		t.Error("expected error when registering a collector with conflicting alias")
+	}
+
+	if !wrapper.Unregister(gauge) {
+		t.Error("failed to unregister registered collector")
+	}
+	if wrapper.Unregister(gauge) {
+		t.Error("unregistered collector which is not registered")
+	}
+	if err := wrapper.Register(other); err != nil {
+		t.Errorf("failed to register collector after unregister: %v", err)
+	}
+}
+
+func TestWrapRegistryNonFiniteInt(t *testing.T) {
+	reg := ksurveyclient.NewRegistry()
+	wrapper := WrapRegistry(reg, map[string]string{
+		"broken_ratio": "broken_ratio,gauge:int",
+	})
+
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "broken_ratio",
+		Help: "Gauge with a non-finite value",
+	})
+	gauge.Set(math.NaN())
+	if err := wrapper.Register(gauge); err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+
+	ms, err := reg.Gather()
+	if err == nil {
+		t.Error("expected error for non-finite int value")
+	}
+	if ms != nil && ms.Get("broken_ratio") != nil {
+		t.Errorf("non-finite int value was gathered: %v", ms.Get("broken_ratio").Fields)
+	}
+}
@@ -17,19 +17,100 @@
 package ksurveyclient
 
 import (
+	"context"
 	"sync"
+	"time"
 )
 
-// A Registry holds registered Collectors and collects their Metrics.
+// A Registry holds registered Collectors and collects their Metrics. It is
+// safe for concurrent use.
 type Registry struct {
+	mutex      sync.RWMutex
 	collectors []Collector
+	names      map[string]Collector
+
+	duplicatePolicy  DuplicatePolicy
+	errorPolicy      ErrorPolicy
+	errorsMetric     bool
+	maxConcurrency   int
+	collectorTimeout time.Duration
+}
+
+// ErrorPolicy defines the result of a gather when Collectors or Metrics fail.
+type ErrorPolicy int
+
+// Supported ErrorPolicies.
+const (
+	// PartialOnError returns the metrics which were gathered successfully
+	// together with the error.
+	PartialOnError ErrorPolicy = iota
+	// FailOnError returns only the error.
+	FailOnError
+)
+
+// Default gather settings of a Registry.
+const (
+	DefaultMaxConcurrency   = 4
+	DefaultCollectorTimeout = 30 * time.Second
+)
+
+// A RegistryOption configures a Registry.
+type RegistryOption func(*Registry)
+
+// WithDuplicatePolicy sets the DuplicatePolicy which is applied when multiple
+// Metrics with the same name are gathered. The default is DuplicateReport.
+func WithDuplicatePolicy(policy DuplicatePolicy) RegistryOption {
+	return func(reg *Registry) {
+		reg.duplicatePolicy = policy
+	}
 }
 
-// NewRegistry creates a new Registry.
-func NewRegistry() *Registry {
-	return &Registry{
+// WithErrorPolicy sets the ErrorPolicy which is applied when Collectors or
+// Metrics fail while gathering.
+func WithErrorPolicy(policy ErrorPolicy) RegistryOption {
+	return func(reg *Registry) {
+		reg.errorPolicy = policy
+	}
+}
+
+// WithCollectorErrorsMetric controls if gathered MetricSets include the
+// "collector_errors" metric, which holds the number and the messages of the
+// errors which occurred while gathering.
+func WithCollectorErrorsMetric(enabled bool) RegistryOption {
+	return func(reg *Registry) {
+		reg.errorsMetric = enabled
+	}
+}
+
+// WithMaxConcurrency sets the maximum number of Collectors which are called
+// at the same time when gathering.
+func WithMaxConcurrency(n int) RegistryOption {
+	return func(reg *Registry) {
+		reg.maxConcurrency = n
+	}
+}
+
+// WithCollectorTimeout sets the time after which a Collector is given up when
+// gathering. A zero duration disables the timeout.
+func WithCollectorTimeout(timeout time.Duration) RegistryOption {
+	return func(reg *Registry) {
+		reg.collectorTimeout = timeout
+	}
+}
+
+// NewRegistry creates a new Registry with the provided options.
+func NewRegistry(opts ...RegistryOption) *Registry {
+	reg := &Registry{
 		collectors: make([]Collector, 0),
+		names:      make(map[string]Collector),
+
+		maxConcurrency:   DefaultMaxConcurrency,
+		collectorTimeout: DefaultCollectorTimeout,
 	}
+	for _, opt := range opts {
+		opt(reg)
+	}
+	return reg
 }
 
 // DefaultRegistry is a Registry which is used by default.
@@ -40,13 +121,97 @@ func init() {
 	DefaultRegistry.MustRegister(NewBasicCollector())
 }
 
-// Register registers the provided Collector with the associated Registry.
+// Register registers the provided Collector with the associated Registry. It
+// fails if the Collector is already registered. If the Collector is also a
+// Describer, it fails if any of the described names are invalid or already
+// described by another registered Collector. Collectors must be of comparable
+// types, like pointers, so they can be identified.
 func (reg *Registry) Register(c Collector) error {
+	if err := validateCollector(c); err != nil {
+		return err
+	}
+
+	var descs []*Desc
+	if d, ok := c.(Describer); ok {
+		descs = describe(d)
+	}
+
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+
+	if idx := reg.indexOf(c); idx >= 0 {
+		return &AlreadyRegisteredError{
+			ExistingCollector: reg.collectors[idx],
+			NewCollector:      c,
+		}
+	}
+
+	var names []string
+	for _, desc := range descs {
+		if err := validateName(desc.Name); err != nil {
+			return err
+		}
+		if existing, ok := reg.names[desc.Name]; ok {
+			return &DuplicateMetricError{
+				Name: desc.Name,
+				Collectors: []string{
+					collectorName(existing, reg.indexOf(existing)),
+					collectorName(c, len(reg.collectors)),
+				},
+			}
+		}
+		names = append(names, desc.Name)
+	}
+
 	reg.collectors = append(reg.collectors, c)
+	for _, name := range names {
+		reg.names[name] = c
+	}
 
 	return nil
 }
 
+// Unregister unregisters the provided Collector from the associated Registry.
+// It returns true if the Collector was registered.
+func (reg *Registry) Unregister(c Collector) bool {
+	reg.mutex.Lock()
+	defer reg.mutex.Unlock()
+
+	idx := reg.indexOf(c)
+	if idx < 0 {
+		return false
+	}
+
+	// NOTE: Create a new slice, since running gathers might still
+	// use the current one.
+	collectors := make([]Collector, 0, len(reg.collectors)-1)
+	collectors = append(collectors, reg.collectors[:idx]...)
+	reg.collectors = append(collectors, reg.collectors[idx+1:]...)
+	for name, existing := range reg.names {
+		if sameCollector(existing, c) {
+			delete(reg.names, name)
+		}
+	}
+
+	return true
+}
+
+// Unregister unregisters the provided Collector from the default Registry.
+func Unregister(c Collector) bool {
+	return DefaultRegistry.Unregister(c)
+}
+
+// indexOf returns the index of the provided Collector in the associated
+// Registry or -1 if not found. The caller must hold the lock.
+func (reg *Registry) indexOf(c Collector) int {
+	for idx, existing := range reg.collectors {
+		if sameCollector(existing, c) {
+			return idx
+		}
+	}
+	return -1
+}
+
 // Register registers the provided Collector with the default Registry.
 func Register(c Collector) error {
 	return DefaultRegistry.Register(c)
@@ -68,66 +233,84 @@ func MustRegister(cs ...Collector) {
 	DefaultRegistry.MustRegister(cs...)
 }
 
-// Gather calls the Collect method of the registered Collectors and then
-// gathers the collected metrics into a MetricSet.
+// Gather calls the Collect method of the registered Collectors concurrently
+// and then gathers the collected metrics into a MetricSet sorted by name.
+// Metrics with the same name are handled according to the DuplicatePolicy of
+// the associated Registry, in the order the Collectors were registered.
+// Reported duplicates are returned as DuplicateMetricErrors and are subject to
+// the ErrorPolicy like failed Collectors.
+//
+// Collectors which panic or do not finish within the collector timeout and
+// Metrics which fail to write are reported with a CollectorError each. A
+// single reported error is returned as is, multiple errors are returned as a
+// MultiError. With the default PartialOnError policy the returned MetricSet
+// holds all successfully gathered metrics in that case, with FailOnError it is
+// nil.
 func (reg *Registry) Gather() (*MetricSet, error) {
-	var wg sync.WaitGroup
-	var num = len(reg.collectors)
-	var metricChan = make(chan Metric, num)
-	collectors := make(chan Collector, num)
+	return reg.GatherContext(context.Background())
+}
+
+// GatherContext is like Gather, but gives up all Collectors which have not
+// finished when the provided Context is done. Collectors implementing
+// CollectorContext get a Context derived from the provided Context.
+func (reg *Registry) GatherContext(ctx context.Context) (*MetricSet, error) {
+	reg.mutex.RLock()
+	registered := reg.collectors
+	reg.mutex.RUnlock()
 
-	for _, collector := range reg.collectors {
-		collectors <- collector
+	collected := make([]*collectedMetrics, len(registered))
+	workers := reg.maxConcurrency
+	if workers <= 0 || workers > len(registered) {
+		workers = len(registered)
 	}
 
-	wg.Add(num)
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	wg.Add(workers)
 	collectWorker := func() {
-		for {
-			select {
-			case collector := <-collectors:
-				collector.Collect(metricChan)
-			default:
-				return
-			}
-			wg.Done()
+		defer wg.Done()
+		for idx := range jobs {
+			collected[idx] = collect(ctx, registered[idx], idx, reg.collectorTimeout)
 		}
 	}
-	go collectWorker()
+	for i := 0; i < workers; i++ {
+		go collectWorker()
+	}
+	for idx := range registered {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
 
-	go func() {
-		wg.Wait()
-		close(metricChan)
-	}()
+	var errs MultiError
+	for _, cm := range collected {
+		errs = append(errs, cm.errs...)
+	}
+	if len(errs) > 0 && reg.errorPolicy == FailOnError {
+		return nil, errs.MaybeUnwrap()
+	}
 
-	defer func() {
-		if metricChan != nil {
-			for range metricChan {
-			}
+	content, duplicates := mergeCollectedMetrics(collected, reg.duplicatePolicy)
+	if len(duplicates) > 0 {
+		if reg.duplicatePolicy == DuplicateError {
+			return nil, duplicates.MaybeUnwrap()
 		}
-	}()
-
-	content := make([]*MetricData, 0)
-	cc := metricChan
-
-	for {
-		select {
-		case metric, ok := <-cc:
-			if !ok {
-				cc = nil
-				break
-			}
-			md := &MetricData{}
-			if err := metric.Write(md); err == nil {
-				content = append(content, md)
-			}
+		errs = append(errs, duplicates...)
+		if reg.errorPolicy == FailOnError {
+			return nil, errs.MaybeUnwrap()
 		}
-
-		if cc == nil {
-			break
+	}
+	if reg.errorsMetric {
+		m, err := newCollectorErrorsMetric(errs)
+		if err != nil {
+			return nil, err
+		}
+		md := &MetricData{}
+		if err := m.Write(md); err != nil {
+			return nil, err
 		}
+		content = append(content, md)
 	}
 
-	return &MetricSet{
-		Content: content,
-	}, nil
+	return NewMetricSet(content), errs.MaybeUnwrap()
 }
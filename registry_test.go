@@ -0,0 +1,216 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var testUsercntDesc = NewDesc("usercnt_active", "Active users", IntValue, GaugeMode)
+
+// testCollector collects the provided Metrics without describing them.
+type testCollector struct {
+	metrics []Metric
+}
+
+func newTestCollector(metrics ...Metric) *testCollector {
+	return &testCollector{
+		metrics: metrics,
+	}
+}
+
+func (c *testCollector) Collect(ch chan<- Metric) {
+	for _, m := range c.metrics {
+		ch <- m
+	}
+}
+
+func TestRegistryGatherDuplicates(t *testing.T) {
+	newRegistry := func(opts ...RegistryOption) *Registry {
+		reg := NewRegistry(opts...)
+		reg.MustRegister(
+			newTestCollector(MustNewConstInt(testUsercntDesc, 3)),
+			newTestCollector(MustNewConstInt(testUsercntDesc, 5)),
+			MustNewConstString(NewDesc("osrelease", "", StringValue, NoMode), "Linux"),
+		)
+		return reg
+	}
+
+	ms, err := newRegistry().Gather()
+	if dupErr, ok := err.(*DuplicateMetricError); !ok {
+		t.Errorf("expected DuplicateMetricError, got %v", err)
+	} else if dupErr.Name != "usercnt_active" || len(dupErr.Collectors) != 2 {
+		t.Errorf("unexpected duplicate error: %v", dupErr)
+	} else if !strings.Contains(dupErr.Error(), "*ksurveyclient.testCollector[1]") {
+		t.Errorf("duplicate error does not name collector: %v", dupErr)
+	}
+	if ms == nil || len(ms.Content) != 2 || ms.Get("usercnt_active").Value() != int64(3) {
+		t.Errorf("unexpected partial metrics with duplicates: %v", ms)
+	}
+
+	ms, err = newRegistry(WithDuplicatePolicy(DuplicateError)).Gather()
+	if _, ok := err.(*DuplicateMetricError); !ok || ms != nil {
+		t.Errorf("expected only DuplicateMetricError with error policy, got %v %v", ms, err)
+	}
+	ms, err = newRegistry(WithErrorPolicy(FailOnError)).Gather()
+	if _, ok := err.(*DuplicateMetricError); !ok || ms != nil {
+		t.Errorf("expected only DuplicateMetricError with fail on error policy, got %v %v", ms, err)
+	}
+
+	for policy, expected := range map[DuplicatePolicy]int64{
+		DuplicateFirst: 3,
+		DuplicateSum:   8,
+		DuplicateMax:   5,
+	} {
+		ms, err := newRegistry(WithDuplicatePolicy(policy)).Gather()
+		if err != nil {
+			t.Errorf("unexpected error with %v policy: %v", policy, err)
+			continue
+		}
+		if len(ms.Content) != 2 {
+			t.Errorf("unexpected number of metrics with %v policy: %d", policy, len(ms.Content))
+			continue
+		}
+		if value := ms.Get("usercnt_active").Value(); value != expected {
+			t.Errorf("unexpected value with %v policy: %v", policy, value)
+		}
+	}
+
+	reg := NewRegistry(WithDuplicatePolicy(DuplicateSum))
+	reg.MustRegister(newTestCollector(
+		MustNewConstString(NewDesc("osrelease", "", StringValue, NoMode), "Linux"),
+		MustNewConstString(NewDesc("osrelease", "", StringValue, NoMode), "BSD"),
+	))
+	if ms, err := reg.Gather(); err == nil {
+		t.Error("expected error when summing string metrics")
+	} else if ms == nil || ms.Get("osrelease").Value() != "Linux" {
+		t.Errorf("unexpected metrics when summing string metrics: %v", ms)
+	}
+}
+
+// sliceCollector is a Collector of a non-comparable type.
+type sliceCollector []Metric
+
+func (c sliceCollector) Collect(ch chan<- Metric) {
+	for _, m := range c {
+		ch <- m
+	}
+}
+
+func TestRegistryRegisterUnregister(t *testing.T) {
+	reg := NewRegistry()
+	if err := reg.Register(sliceCollector{}); err == nil {
+		t.Error("expected error when registering a non-comparable collector")
+	}
+	if err := WrapRegistryWithPrefix("wrapped_", reg).Register(sliceCollector{}); err == nil {
+		t.Error("expected error when registering a wrapped non-comparable collector")
+	}
+	if err := reg.Register(nil); err == nil {
+		t.Error("expected error when registering a nil collector")
+	}
+	bc := NewProgramCollector("test", "1.0", testGUID)
+	if err := reg.Register(bc); err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+	if err := reg.Register(bc); err == nil {
+		t.Error("expected error when registering the same collector twice")
+	} else if _, ok := err.(*AlreadyRegisteredError); !ok {
+		t.Errorf("unexpected error type: %T", err)
+	}
+	if err := reg.Register(NewProgramCollector("other", "", nil)); err == nil {
+		t.Error("expected error when registering a conflicting collector")
+	} else if dupErr, ok := err.(*DuplicateMetricError); !ok || dupErr.Name != "program_name" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := reg.Register(newTestCollector()); err != nil {
+		t.Errorf("failed to register collector without descriptions: %v", err)
+	}
+	if err := reg.Register(MustNewConstInt(testUsercntDesc, 1)); err != nil {
+		t.Errorf("failed to register const metric: %v", err)
+	}
+
+	if !reg.Unregister(bc) {
+		t.Error("failed to unregister registered collector")
+	}
+	if reg.Unregister(bc) {
+		t.Error("unregistered collector which is not registered")
+	}
+	if err := reg.Register(NewProgramCollector("other", "", nil)); err != nil {
+		t.Errorf("failed to register collector after unregister: %v", err)
+	}
+
+	ms, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+	if len(ms.Content) != 4 {
+		t.Errorf("unexpected number of metrics: %d", len(ms.Content))
+	}
+}
+
+func TestRegistryConcurrency(t *testing.T) {
+	reg := NewRegistry()
+	reg.MustRegister(NewBasicCollector())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(3)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				c := MustNewConstInt(NewDesc(fmt.Sprintf("metric_%d_%d", i, j), "", IntValue, GaugeMode), int64(j))
+				if err := reg.Register(c); err != nil {
+					t.Errorf("failed to register: %v", err)
+					return
+				}
+				if j%2 == 0 && !reg.Unregister(c) {
+					t.Errorf("failed to unregister")
+					return
+				}
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if _, err := reg.Gather(); err != nil {
+					t.Errorf("failed to gather: %v", err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			c := newTestCollector()
+			for j := 0; j < 50; j++ {
+				reg.MustRegister(c)
+				reg.Unregister(c)
+			}
+		}()
+	}
+	wg.Wait()
+
+	ms, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+	if len(ms.Content) != 3+8*25 {
+		t.Errorf("unexpected number of metrics: %d", len(ms.Content))
+	}
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+// PayloadSchema is the JSON Schema of JSON encoded Payloads of all supported
+// versions.
+const PayloadSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "https://stats.kopano.io/schemas/ksurvey-payload.json",
+  "title": "Kopano survey payload",
+  "type": "object",
+  "required": ["version", "stats"],
+  "properties": {
+    "version": {
+      "description": "Version of the payload format.",
+      "enum": [2, 3]
+    },
+    "envelope": {
+      "$ref": "#/definitions/envelope"
+    },
+    "stats": {
+      "$ref": "#/definitions/stats"
+    }
+  },
+  "additionalProperties": false,
+  "anyOf": [
+    {
+      "properties": {"version": {"const": 2}},
+      "not": {"required": ["envelope"]}
+    },
+    {
+      "properties": {"version": {"const": 3}},
+      "required": ["envelope"]
+    }
+  ],
+  "definitions": {
+    "envelope": {
+      "description": "Metadata of the payload, since version 3.",
+      "type": "object",
+      "required": ["library_version", "sequence", "timestamp", "tz_offset", "schema_version"],
+      "properties": {
+        "library_version": {"type": "string"},
+        "product": {"type": "string"},
+        "product_version": {"type": "string"},
+        "installation_id": {"type": "string"},
+        "sequence": {"type": "integer", "minimum": 0},
+        "timestamp": {"type": "integer"},
+        "tz_offset": {"type": "integer"},
+        "consent_level": {"type": "string"},
+        "schema_version": {"type": "integer", "minimum": 1}
+      }
+    },
+    "stats": {
+      "description": "Metrics by name.",
+      "type": "object",
+      "propertyNames": {"pattern": "^[A-Za-z_:][A-Za-z0-9_:]*$"},
+      "additionalProperties": {"$ref": "#/definitions/metric"}
+    },
+    "metric": {
+      "type": "object",
+      "required": ["type"],
+      "properties": {
+        "desc": {"type": "string"},
+        "type": {"enum": ["string", "int", "float", "bool"]},
+        "mode": {"enum": ["", "gauge", "counter", "info"]},
+        "labels": {
+          "description": "Label names of a vector.",
+          "type": "array",
+          "items": {"type": "string", "pattern": "^[A-Za-z_][A-Za-z0-9_]*$"}
+        },
+        "values": {
+          "description": "Labeled values of a vector.",
+          "type": "array",
+          "items": {
+            "type": "object",
+            "required": ["labels", "value"],
+            "properties": {
+              "labels": {
+                "type": "object",
+                "additionalProperties": {"type": "string"}
+              }
+            }
+          }
+        },
+        "overflow": {"type": "integer", "minimum": 0}
+      },
+      "anyOf": [
+        {"required": ["value"], "not": {"required": ["values"]}},
+        {"required": ["values", "labels"], "not": {"required": ["value"]}}
+      ],
+      "oneOf": [
+        {"$ref": "#/definitions/typedString"},
+        {"$ref": "#/definitions/typedInt"},
+        {"$ref": "#/definitions/typedFloat"},
+        {"$ref": "#/definitions/typedBool"}
+      ]
+    },
+    "typedString": {
+      "properties": {
+        "type": {"const": "string"},
+        "value": {"type": "string"},
+        "values": {"items": {"properties": {"value": {"type": "string"}}}}
+      }
+    },
+    "typedInt": {
+      "properties": {
+        "type": {"const": "int"},
+        "value": {"type": "integer"},
+        "values": {"items": {"properties": {"value": {"type": "integer"}}}}
+      }
+    },
+    "typedFloat": {
+      "properties": {
+        "type": {"const": "float"},
+        "value": {"type": "number"},
+        "values": {"items": {"properties": {"value": {"type": "number"}}}}
+      }
+    },
+    "typedBool": {
+      "properties": {
+        "type": {"const": "bool"},
+        "value": {"type": "boolean"},
+        "values": {"items": {"properties": {"value": {"type": "boolean"}}}}
+      }
+    }
+  }
+}
+`
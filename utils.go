@@ -16,6 +16,10 @@
 
 package ksurveyclient
 
+import (
+	"time"
+)
+
 func charsToString(chars []int8) string {
 	s := make([]byte, len(chars))
 	var i int
@@ -27,3 +31,10 @@ func charsToString(chars []int8) string {
 	}
 	return string(s[0:i])
 }
+
+func secondsOrDefault(seconds, fallback uint64) time.Duration {
+	if seconds == 0 {
+		seconds = fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,395 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A ValidationError describes a violation of PayloadSchema at the location
+// given by Path as JSON Pointer.
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (err *ValidationError) Error() string {
+	path := err.Path
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("invalid payload at %s: %s", path, err.Message)
+}
+
+// Validate validates the JSON encoding of the provided Payload against
+// PayloadSchema. Clients can use it before submitting a Payload.
+func Validate(payload *Payload) error {
+	payload, err := checkPayload(payload)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return ValidateJSON(data)
+}
+
+// ValidateJSON validates the provided JSON encoded Payload against
+// PayloadSchema. Violations are returned as ValidationErrors, wrapped into a
+// MultiError if there is more than one.
+func ValidateJSON(data []byte) error {
+	instance, err := decodeJSONNumbers(data)
+	if err != nil {
+		return fmt.Errorf("invalid payload: %v", err)
+	}
+
+	v, err := payloadSchemaValidator()
+	if err != nil {
+		return err
+	}
+	return v.validate(v.root, instance, "").MaybeUnwrap()
+}
+
+var (
+	payloadSchemaOnce sync.Once
+	payloadSchema     *schemaValidator
+	payloadSchemaErr  error
+)
+
+func payloadSchemaValidator() (*schemaValidator, error) {
+	payloadSchemaOnce.Do(func() {
+		payloadSchema, payloadSchemaErr = newSchemaValidator([]byte(PayloadSchema))
+	})
+	return payloadSchema, payloadSchemaErr
+}
+
+func decodeJSONNumbers(data []byte) (interface{}, error) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// schemaValidator validates JSON values decoded with json.Number support
+// against the subset of JSON Schema draft-07 which is used by PayloadSchema.
+type schemaValidator struct {
+	root map[string]interface{}
+
+	mutex    sync.Mutex
+	patterns map[string]*regexp.Regexp
+}
+
+func newSchemaValidator(schema []byte) (*schemaValidator, error) {
+	root, err := decodeJSONNumbers(schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %v", err)
+	}
+	v := &schemaValidator{
+		patterns: make(map[string]*regexp.Regexp),
+	}
+	var ok bool
+	if v.root, ok = root.(map[string]interface{}); !ok {
+		return nil, errors.New("invalid schema: not an object")
+	}
+	return v, nil
+}
+
+func (v *schemaValidator) resolve(ref string) (map[string]interface{}, error) {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported schema reference: %v", ref)
+	}
+	var current interface{} = v.root
+	for _, part := range strings.Split(ref[2:], "/") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid schema reference: %v", ref)
+		}
+		current = m[part]
+	}
+	schema, ok := current.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid schema reference: %v", ref)
+	}
+	return schema, nil
+}
+
+func (v *schemaValidator) pattern(expr string) (*regexp.Regexp, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if re, ok := v.patterns[expr]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	v.patterns[expr] = re
+	return re, nil
+}
+
+func (v *schemaValidator) validate(schema map[string]interface{}, instance interface{}, path string) MultiError {
+	var errs MultiError
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, &ValidationError{
+			Path:    path,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, err := v.resolve(ref)
+		if err != nil {
+			fail("%v", err)
+			return errs
+		}
+		return v.validate(resolved, instance, path)
+	}
+
+	if t, ok := schema["type"]; ok && !matchesType(t, instance) {
+		fail("expected %v, got %s", t, jsonTypeName(instance))
+		// Further checks make no sense with the wrong type.
+		return errs
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if jsonEqual(allowed, instance) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("value %v is not one of %v", instance, enum)
+		}
+	}
+	if c, ok := schema["const"]; ok && !jsonEqual(c, instance) {
+		fail("value %v is not %v", instance, c)
+	}
+	if minimum, ok := schema["minimum"].(json.Number); ok {
+		if n, isNumber := instance.(json.Number); isNumber {
+			min, _ := minimum.Float64()
+			if f, _ := n.Float64(); f < min {
+				fail("value %v is less than %v", n, minimum)
+			}
+		}
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		if s, isString := instance.(string); isString {
+			re, err := v.pattern(pattern)
+			if err != nil {
+				fail("invalid schema pattern: %v", err)
+			} else if !re.MatchString(s) {
+				fail("value %q does not match %v", s, pattern)
+			}
+		}
+	}
+
+	if object, ok := instance.(map[string]interface{}); ok {
+		errs = append(errs, v.validateObject(schema, object, path)...)
+	}
+	if array, ok := instance.([]interface{}); ok {
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for idx, item := range array {
+				errs = append(errs, v.validate(items, item, fmt.Sprintf("%s/%d", path, idx))...)
+			}
+		}
+	}
+
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		matched := false
+		for _, sub := range anyOf {
+			if subSchema, ok := sub.(map[string]interface{}); ok && len(v.validate(subSchema, instance, path)) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("value does not match any of the allowed variants")
+		}
+	}
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		matched := 0
+		for _, sub := range oneOf {
+			if subSchema, ok := sub.(map[string]interface{}); ok && len(v.validate(subSchema, instance, path)) == 0 {
+				matched++
+			}
+		}
+		if matched != 1 {
+			fail("value matches %d instead of exactly one of the allowed variants", matched)
+		}
+	}
+	if not, ok := schema["not"].(map[string]interface{}); ok {
+		if len(v.validate(not, instance, path)) == 0 {
+			fail("value matches a disallowed variant")
+		}
+	}
+
+	return errs
+}
+
+func (v *schemaValidator) validateObject(schema map[string]interface{}, object map[string]interface{}, path string) MultiError {
+	var errs MultiError
+
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if key, ok := name.(string); ok {
+				if _, found := object[key]; !found {
+					errs = append(errs, &ValidationError{
+						Path:    path,
+						Message: fmt.Sprintf("missing required property %q", key),
+					})
+				}
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	propertyNames, _ := schema["propertyNames"].(map[string]interface{})
+	for _, key := range sortedKeys(object) {
+		value := object[key]
+		propertyPath := path + "/" + escapeJSONPointer(key)
+		if propertyNames != nil {
+			errs = append(errs, v.validate(propertyNames, key, propertyPath)...)
+		}
+		if propertySchema, ok := properties[key].(map[string]interface{}); ok {
+			errs = append(errs, v.validate(propertySchema, value, propertyPath)...)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				errs = append(errs, &ValidationError{
+					Path:    propertyPath,
+					Message: "property not allowed",
+				})
+			}
+		case map[string]interface{}:
+			errs = append(errs, v.validate(additional, value, propertyPath)...)
+		}
+	}
+
+	return errs
+}
+
+func matchesType(t interface{}, instance interface{}) bool {
+	switch t := t.(type) {
+	case string:
+		switch t {
+		case "integer":
+			n, ok := instance.(json.Number)
+			if !ok {
+				return false
+			}
+			f, err := n.Float64()
+			return err == nil && f == math.Trunc(f)
+		case "number":
+			_, ok := instance.(json.Number)
+			return ok
+		default:
+			return jsonTypeName(instance) == t
+		}
+	case []interface{}:
+		for _, sub := range t {
+			if matchesType(sub, instance) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func jsonTypeName(instance interface{}) string {
+	switch instance.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", instance)
+	}
+}
+
+func jsonEqual(a, b interface{}) bool {
+	if an, ok := a.(json.Number); ok {
+		bn, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		af, aErr := an.Float64()
+		bf, bErr := bn.Float64()
+		return aErr == nil && bErr == nil && af == bf
+	}
+	switch av := a.(type) {
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for idx := range av {
+			if !jsonEqual(av[idx], bv[idx]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for key := range av {
+			if !jsonEqual(av[key], bv[key]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func escapeJSONPointer(s string) string {
+	return strings.Replace(strings.Replace(s, "~", "~0", -1), "/", "~1", -1)
+}
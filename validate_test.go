@@ -0,0 +1,98 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestValidateBuiltinCollectors validates the payloads of the Collectors of
+// this package. The Collectors of the counter and prometrics packages are
+// validated in their own tests.
+func TestValidateBuiltinCollectors(t *testing.T) {
+	vec := MustNewGaugeVec(NewDesc("usercnt_by_state", "Users by state", IntValue, GaugeMode), []string{"state"}, WithMaxCardinality(1))
+	vec.WithLabelValues("active").Set(3)
+	vec.WithLabelValues("idle").Set(1)
+
+	reg := NewRegistry(WithCollectorErrorsMetric(true))
+	reg.MustRegister(
+		NewBasicCollector(),
+		NewProgramCollector("kopano-test", "1.2.3", testGUID),
+		NewClientMetrics(),
+		MustNewConstInt(testUsercntDesc, 42),
+		MustNewConstFloat(NewDesc("ratio", "Some ratio", FloatValue, GaugeMode), 0.5),
+		MustNewConstBool(NewDesc("enabled", "", BoolValue, InfoMode), true),
+		MustNewGaugeFunc(NewDesc("load", "", FloatValue, GaugeMode), func() float64 { return 0.25 }),
+		MustNewCounterFunc(NewDesc("requests_total", "", IntValue, CounterMode), func() float64 { return 7 }),
+		MustNewStringFunc(NewDesc("state", "", StringValue, InfoMode), func() string { return "running" }),
+		NewCachedCollector(MustNewConstInt(NewDesc("cached_value", "", IntValue, GaugeMode), 1), time.Minute),
+		vec,
+		&panickingCollector{},
+	)
+	if err := WrapRegistryWithPrefix("wrapped_", reg).Register(MustNewConstInt(testUsercntDesc, 1)); err != nil {
+		t.Fatalf("failed to register wrapped collector: %v", err)
+	}
+	ms, err := reg.Gather()
+	if ms == nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+
+	for _, payload := range []*Payload{
+		{Version: PayloadV2, Stats: ms},
+		{Version: PayloadV3, Envelope: NewEnvelope(ms, time.Now(), 1, ConsentBasic), Stats: ms},
+	} {
+		if err := Validate(payload); err != nil {
+			t.Errorf("v%d payload is invalid: %v", payload.Version, err)
+		}
+	}
+}
+
+func TestValidateJSON(t *testing.T) {
+	for _, tc := range []struct {
+		raw  string
+		path string
+	}{
+		{`[]`, "/"},
+		{`{"version": 2}`, "/"},
+		{`{"version": 4, "stats": {}}`, "/version"},
+		{`{"version": 2, "stats": {}, "extra": true}`, "/extra"},
+		{`{"version": 3, "stats": {}}`, "/"},
+		{`{"version": 2, "stats": {}, "envelope": {"library_version": "1.0", "sequence": 1, "timestamp": 0, "tz_offset": 0, "schema_version": 1}}`, "/"},
+		{`{"version": 3, "stats": {}, "envelope": {"library_version": "1.0", "sequence": -1, "timestamp": 0, "tz_offset": 0, "schema_version": 1}}`, "/envelope/sequence"},
+		{`{"version": 2, "stats": {"bad-name": {"type": "int", "value": 1}}}`, "/stats/bad-name"},
+		{`{"version": 2, "stats": {"a": {"type": "int"}}}`, "/stats/a"},
+		{`{"version": 2, "stats": {"a": {"type": "int", "value": 1.5}}}`, "/stats/a"},
+		{`{"version": 2, "stats": {"a": {"type": "bool", "value": "yes"}}}`, "/stats/a"},
+		{`{"version": 2, "stats": {"a": {"type": "int", "value": 1, "mode": "rate"}}}`, "/stats/a/mode"},
+	} {
+		err := ValidateJSON([]byte(tc.raw))
+		if err == nil {
+			t.Errorf("expected error for %s", tc.raw)
+			continue
+		}
+		if !strings.Contains(err.Error(), "at "+tc.path+":") {
+			t.Errorf("unexpected error for %s: %v", tc.raw, err)
+		}
+	}
+
+	valid := `{"version": 2, "stats": {"job:a": {"desc": "", "type": "float", "value": 1, "mode": "gauge", "extra": [1, 2]}}}`
+	if err := ValidateJSON([]byte(valid)); err != nil {
+		t.Errorf("unexpected error for %s: %v", valid, err)
+	}
+}
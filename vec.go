@@ -0,0 +1,323 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultMaxCardinality is the default maximum number of label value
+// combinations of a vector.
+const DefaultMaxCardinality = 100
+
+// ErrCardinalityExceeded is returned when a vector already holds the maximum
+// number of label value combinations.
+var ErrCardinalityExceeded = errors.New("maximum cardinality exceeded")
+
+// A Gauge is a numeric value which can go up and down.
+type Gauge interface {
+	Set(value float64)
+	Add(delta float64)
+	Sub(delta float64)
+	Inc()
+	Dec()
+}
+
+// A VecOption configures a vector.
+type VecOption func(*GaugeVec)
+
+// WithMaxCardinality sets the maximum number of label value combinations of a
+// vector. Zero or less removes the limit.
+func WithMaxCardinality(n int) VecOption {
+	return func(v *GaugeVec) {
+		v.maxCardinality = n
+	}
+}
+
+// A GaugeVec holds Gauges with the same Desc which are distinguished by
+// their label values. It is a Metric which is written with all its Gauges in
+// the "values" field, each with its "labels" and "value", and "labels" holding
+// the label names. It is also a Collector collecting itself.
+type GaugeVec struct {
+	// NOTE: Keep first for 64-bit aligned atomic access.
+	overflow uint64
+
+	desc           *Desc
+	labelNames     []string
+	maxCardinality int
+
+	mutex    sync.RWMutex
+	children map[string]*vecGauge
+
+	selfCollector
+}
+
+// NewGaugeVec creates a new GaugeVec with the provided Desc, label names and
+// options. The ValueType of the Desc defaults to FloatValue, its Mode to
+// GaugeMode. If the Desc declares IntValue, the Gauges still hold float values
+// which are truncated towards zero when written, like a conversion to int64.
+func NewGaugeVec(desc *Desc, labelNames []string, opts ...VecOption) (*GaugeVec, error) {
+	d := *desc
+	if d.ValueType == "" {
+		d.ValueType = FloatValue
+	}
+	if d.Mode == NoMode {
+		d.Mode = GaugeMode
+	}
+	if err := validateName(d.Name); err != nil {
+		return nil, err
+	}
+	switch d.ValueType {
+	case IntValue, FloatValue:
+	default:
+		return nil, fmt.Errorf("metric %q: unsupported vector type %q", d.Name, d.ValueType)
+	}
+	if len(labelNames) == 0 {
+		return nil, fmt.Errorf("metric %q: no label names", d.Name)
+	}
+	seen := make(map[string]bool, len(labelNames))
+	for _, name := range labelNames {
+		if err := validateLabelName(name); err != nil {
+			return nil, fmt.Errorf("metric %q: %v", d.Name, err)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("metric %q: duplicate label %q", d.Name, name)
+		}
+		seen[name] = true
+	}
+
+	v := &GaugeVec{
+		desc:           &d,
+		labelNames:     append([]string(nil), labelNames...),
+		maxCardinality: DefaultMaxCardinality,
+		children:       make(map[string]*vecGauge),
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	v.init(v)
+	return v, nil
+}
+
+// MustNewGaugeVec is like NewGaugeVec but panics if an error occurs.
+func MustNewGaugeVec(desc *Desc, labelNames []string, opts ...VecOption) *GaugeVec {
+	v, err := NewGaugeVec(desc, labelNames, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// GetMetricWithLabelValues returns the Gauge for the provided label values,
+// which must match the label names in number and order, creating it if it
+// does not exist yet. It fails with ErrCardinalityExceeded if creating the
+// Gauge would exceed the maximum cardinality of the associated GaugeVec.
+func (v *GaugeVec) GetMetricWithLabelValues(labelValues ...string) (Gauge, error) {
+	if len(labelValues) != len(v.labelNames) {
+		return nil, fmt.Errorf("metric %q: expected %d label values, got %d", v.desc.Name, len(v.labelNames), len(labelValues))
+	}
+	key := labelValuesKey(labelValues)
+
+	v.mutex.RLock()
+	g, ok := v.children[key]
+	v.mutex.RUnlock()
+	if ok {
+		return g, nil
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if g, ok = v.children[key]; ok {
+		return g, nil
+	}
+	if v.maxCardinality > 0 && len(v.children) >= v.maxCardinality {
+		atomic.AddUint64(&v.overflow, 1)
+		return nil, ErrCardinalityExceeded
+	}
+	g = &vecGauge{
+		labelValues: append([]string(nil), labelValues...),
+	}
+	v.children[key] = g
+	return g, nil
+}
+
+// WithLabelValues is like GetMetricWithLabelValues but panics if the number
+// of label values is wrong. When the maximum cardinality is exceeded, it
+// returns a Gauge which discards all values and counts the overflow, which is
+// written in the "overflow" field.
+func (v *GaugeVec) WithLabelValues(labelValues ...string) Gauge {
+	g, err := v.GetMetricWithLabelValues(labelValues...)
+	switch err {
+	case nil:
+		return g
+	case ErrCardinalityExceeded:
+		return discardGauge{}
+	default:
+		panic(err)
+	}
+}
+
+// DeleteLabelValues removes the Gauge with the provided label values. It
+// returns true if it existed.
+func (v *GaugeVec) DeleteLabelValues(labelValues ...string) bool {
+	if len(labelValues) != len(v.labelNames) {
+		return false
+	}
+	key := labelValuesKey(labelValues)
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if _, ok := v.children[key]; !ok {
+		return false
+	}
+	delete(v.children, key)
+	return true
+}
+
+// Reset removes all Gauges of the associated GaugeVec.
+func (v *GaugeVec) Reset() {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.children = make(map[string]*vecGauge)
+}
+
+// Write writes all Gauges of the associated GaugeVec sorted by their label
+// values. An empty GaugeVec writes nothing.
+func (v *GaugeVec) Write(md *MetricData) error {
+	v.mutex.RLock()
+	children := make([]*vecGauge, 0, len(v.children))
+	for _, g := range v.children {
+		children = append(children, g)
+	}
+	v.mutex.RUnlock()
+	if len(children) == 0 {
+		return nil
+	}
+	sort.Slice(children, func(i, j int) bool {
+		a, b := children[i].labelValues, children[j].labelValues
+		for idx := range a {
+			if a[idx] != b[idx] {
+				return a[idx] < b[idx]
+			}
+		}
+		return false
+	})
+
+	values := make([]map[string]interface{}, len(children))
+	for idx, g := range children {
+		labels := make(map[string]string, len(v.labelNames))
+		for labelIdx, name := range v.labelNames {
+			labels[name] = g.labelValues[labelIdx]
+		}
+		value := g.value()
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return fmt.Errorf("metric %q: float value %v is not finite", v.desc.Name, value)
+		}
+		entry := map[string]interface{}{
+			"labels": labels,
+		}
+		if v.desc.ValueType == IntValue {
+			entry["value"] = int64(value)
+		} else {
+			entry["value"] = value
+		}
+		values[idx] = entry
+	}
+
+	fields := map[string]interface{}{
+		"desc":   v.desc.Help,
+		"type":   string(v.desc.ValueType),
+		"mode":   string(v.desc.Mode),
+		"labels": append([]string(nil), v.labelNames...),
+		"values": values,
+	}
+	if overflow := atomic.LoadUint64(&v.overflow); overflow > 0 {
+		fields["overflow"] = int64(overflow)
+	}
+	md.Name = v.desc.Name
+	md.Fields = fields
+	return nil
+}
+
+// labelValuesKey returns a unique key for the provided label values by
+// prefixing each value with its length.
+func labelValuesKey(labelValues []string) string {
+	var b strings.Builder
+	for _, value := range labelValues {
+		b.WriteString(strconv.Itoa(len(value)))
+		b.WriteByte(':')
+		b.WriteString(value)
+	}
+	return b.String()
+}
+
+// Describe sends the Desc of the associated GaugeVec.
+func (v *GaugeVec) Describe(ch chan<- *Desc) {
+	ch <- v.desc
+}
+
+type vecGauge struct {
+	// NOTE: Keep first for 64-bit aligned atomic access.
+	bits uint64
+
+	labelValues []string
+}
+
+func (g *vecGauge) value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+func (g *vecGauge) Set(value float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(value))
+}
+
+func (g *vecGauge) Add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&g.bits)
+		updated := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&g.bits, old, updated) {
+			return
+		}
+	}
+}
+
+func (g *vecGauge) Sub(delta float64) {
+	g.Add(-delta)
+}
+
+func (g *vecGauge) Inc() {
+	g.Add(1)
+}
+
+func (g *vecGauge) Dec() {
+	g.Add(-1)
+}
+
+type discardGauge struct{}
+
+func (discardGauge) Set(float64) {}
+func (discardGauge) Add(float64) {}
+func (discardGauge) Sub(float64) {}
+func (discardGauge) Inc()        {}
+func (discardGauge) Dec()        {}
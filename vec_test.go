@@ -0,0 +1,135 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestGaugeVec(t *testing.T) {
+	vec := MustNewGaugeVec(NewDesc("usercnt", "Users per backend", IntValue, NoMode), []string{"backend", "kind"}, WithMaxCardinality(3))
+	vec.WithLabelValues("ldap", "active").Set(3)
+	vec.WithLabelValues("db", "active").Inc()
+	vec.WithLabelValues("db", "active").Add(2)
+	vec.WithLabelValues("db", "shared").Set(1)
+	vec.WithLabelValues("db", "shared").Dec()
+	vec.WithLabelValues("files", "active").Set(10)
+	if _, err := vec.GetMetricWithLabelValues("files", "active"); err != ErrCardinalityExceeded {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := vec.GetMetricWithLabelValues("db"); err == nil {
+		t.Error("expected error for wrong number of label values")
+	}
+
+	reg := NewRegistry()
+	reg.MustRegister(vec)
+	if err := reg.Register(MustNewConstInt(NewDesc("usercnt", "", IntValue, GaugeMode), 1)); err == nil {
+		t.Error("expected error for conflicting name")
+	}
+	ms, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+
+	md := ms.Get("usercnt")
+	if md == nil || md.ValueType() != IntValue || md.Mode() != GaugeMode {
+		t.Fatalf("unexpected metric: %v", md)
+	}
+	expected := []map[string]interface{}{
+		{"labels": map[string]string{"backend": "db", "kind": "active"}, "value": int64(3)},
+		{"labels": map[string]string{"backend": "db", "kind": "shared"}, "value": int64(0)},
+		{"labels": map[string]string{"backend": "ldap", "kind": "active"}, "value": int64(3)},
+	}
+	if values := md.Fields["values"]; !reflect.DeepEqual(values, expected) {
+		t.Errorf("unexpected values: %v", values)
+	}
+	if overflow := md.Fields["overflow"]; overflow != int64(2) {
+		t.Errorf("unexpected overflow: %v", overflow)
+	}
+
+	payload := &Payload{Version: PayloadV2, Stats: ms}
+	if err := Validate(payload); err != nil {
+		t.Errorf("invalid payload: %v", err)
+	}
+	for _, encoding := range []string{EncodingJSON, EncodingCBOR} {
+		encoder, _ := NewEncoder(encoding)
+		decoder, _ := NewDecoder(encoder.ContentType())
+		var buf bytes.Buffer
+		if err := encoder.Encode(&buf, payload); err != nil {
+			t.Fatalf("failed to encode %v: %v", encoding, err)
+		}
+		decoded, err := decoder.Decode(&buf)
+		if err != nil {
+			t.Fatalf("failed to decode %v: %v", encoding, err)
+		}
+		values := decoded.Stats.Get("usercnt").Fields["values"].([]interface{})
+		entry := values[0].(map[string]interface{})
+		if entry["value"] != int64(3) || !reflect.DeepEqual(entry["labels"], map[string]interface{}{"backend": "db", "kind": "active"}) {
+			t.Errorf("%v: unexpected decoded values: %v", encoding, values)
+		}
+	}
+
+	if vec.DeleteLabelValues("db") || vec.DeleteLabelValues("db", "unknown") {
+		t.Error("deleted gauge which does not exist")
+	}
+	if !vec.DeleteLabelValues("db", "shared") {
+		t.Error("failed to delete existing gauge")
+	}
+
+	vec.Reset()
+	ms, _ = reg.Gather()
+	if ms.Get("usercnt") != nil {
+		t.Error("empty vector was written")
+	}
+
+	for _, labelNames := range [][]string{nil, {"a", "a"}, {"bad-label"}, {"job:name"}} {
+		if _, err := NewGaugeVec(NewDesc("vec", "", "", NoMode), labelNames); err == nil {
+			t.Errorf("expected error for label names %v", labelNames)
+		}
+	}
+}
+
+func TestValidateVectors(t *testing.T) {
+	for _, raw := range []string{
+		`{"version": 2, "stats": {"a": {"type": "int", "labels": ["x"], "values": [{"labels": {"x": "1"}, "value": 1.5}]}}}`,
+		`{"version": 2, "stats": {"a": {"type": "int", "labels": ["x"], "values": [{"labels": {"x": 1}, "value": 1}]}}}`,
+		`{"version": 2, "stats": {"a": {"type": "int", "labels": ["x"], "values": [], "value": 1}}}`,
+		`{"version": 2, "stats": {"a": {"type": "int", "values": []}}}`,
+	} {
+		if err := ValidateJSON([]byte(raw)); err == nil {
+			t.Errorf("expected error for %s", raw)
+		}
+	}
+}
+
+func TestGaugeVecLabelValuesKey(t *testing.T) {
+	vec := MustNewGaugeVec(NewDesc("vec", "", "", NoMode), []string{"a", "b"})
+	vec.WithLabelValues("x\xffy", "z").Set(1)
+	vec.WithLabelValues("x", "y\xffz").Set(2)
+	vec.WithLabelValues("1:x", "").Set(3)
+	vec.WithLabelValues("", "1:x").Set(4)
+
+	md := &MetricData{}
+	if err := vec.Write(md); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if values := md.Fields["values"].([]map[string]interface{}); len(values) != 4 {
+		t.Errorf("label values collided: %v", values)
+	}
+}
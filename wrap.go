@@ -0,0 +1,251 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// A Registerer registers and unregisters Collectors. It is implemented by
+// Registry and by the wrappers returned by WrapRegistryWithPrefix and
+// WrapRegistryWithFields.
+type Registerer interface {
+	Register(c Collector) error
+	MustRegister(cs ...Collector)
+	Unregister(c Collector) bool
+}
+
+type wrappingRegisterer struct {
+	registerer Registerer
+	prefix     string
+	fields     map[string]interface{}
+
+	mutex   sync.Mutex
+	wrapped []*wrappingCollector
+}
+
+// WrapRegistryWithPrefix returns a Registerer which registers Collectors with
+// the provided Registerer, prepending the provided prefix to the names of all
+// Metrics they collect. If the provided Registerer is nil, the DefaultRegistry
+// is used.
+func WrapRegistryWithPrefix(prefix string, reg Registerer) Registerer {
+	return wrapRegistry(prefix, nil, reg)
+}
+
+// WrapRegistryWithFields returns a Registerer which registers Collectors with
+// the provided Registerer, adding the provided constant fields to all Metrics
+// they collect. The fields must not use the names "desc", "type", "value" or
+// "mode" and not be set by the collected Metrics. If the provided Registerer
+// is nil, the DefaultRegistry is used.
+func WrapRegistryWithFields(fields map[string]interface{}, reg Registerer) Registerer {
+	return wrapRegistry("", fields, reg)
+}
+
+func wrapRegistry(prefix string, fields map[string]interface{}, reg Registerer) Registerer {
+	if reg == nil {
+		reg = DefaultRegistry
+	}
+	copied := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		copied[key] = value
+	}
+
+	return &wrappingRegisterer{
+		registerer: reg,
+		prefix:     prefix,
+		fields:     copied,
+	}
+}
+
+func (wr *wrappingRegisterer) validate() error {
+	if wr.prefix != "" {
+		if err := validateName(wr.prefix); err != nil {
+			return fmt.Errorf("invalid prefix: %v", err)
+		}
+	}
+	for key, value := range wr.fields {
+		switch key {
+		case "desc", "type", "value", "mode":
+			return fmt.Errorf("invalid constant field %q: reserved name", key)
+		}
+		if _, err := json.Marshal(value); err != nil {
+			return fmt.Errorf("invalid constant field %q: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// Register wraps the provided Collector and registers it with the wrapped
+// Registerer.
+func (wr *wrappingRegisterer) Register(c Collector) error {
+	if err := wr.validate(); err != nil {
+		return err
+	}
+	if err := validateCollector(c); err != nil {
+		return err
+	}
+
+	wr.mutex.Lock()
+	defer wr.mutex.Unlock()
+
+	if idx := wr.indexOf(c); idx >= 0 {
+		return &AlreadyRegisteredError{
+			ExistingCollector: wr.wrapped[idx].collector,
+			NewCollector:      c,
+		}
+	}
+	wrapped := &wrappingCollector{
+		collector: c,
+		prefix:    wr.prefix,
+		fields:    wr.fields,
+	}
+	if err := wr.registerer.Register(wrapped); err != nil {
+		return err
+	}
+	wr.wrapped = append(wr.wrapped, wrapped)
+
+	return nil
+}
+
+// MustRegister registers the provided Collectors with the accociated
+// Registerer and panics if any error occurs.
+func (wr *wrappingRegisterer) MustRegister(cs ...Collector) {
+	for _, c := range cs {
+		if err := wr.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Unregister unregisters the provided Collector from the wrapped Registerer.
+// It returns true if the Collector was registered.
+func (wr *wrappingRegisterer) Unregister(c Collector) bool {
+	wr.mutex.Lock()
+	defer wr.mutex.Unlock()
+
+	idx := wr.indexOf(c)
+	if idx < 0 {
+		return false
+	}
+	wrapped := wr.wrapped[idx]
+	wr.wrapped = append(wr.wrapped[:idx], wr.wrapped[idx+1:]...)
+
+	return wr.registerer.Unregister(wrapped)
+}
+
+func (wr *wrappingRegisterer) indexOf(c Collector) int {
+	for idx, wrapped := range wr.wrapped {
+		if sameCollector(wrapped.collector, c) {
+			return idx
+		}
+	}
+	return -1
+}
+
+type wrappingCollector struct {
+	collector Collector
+	prefix    string
+	fields    map[string]interface{}
+}
+
+func (wc *wrappingCollector) Collect(ch chan<- Metric) {
+	wc.CollectContext(context.Background(), ch)
+}
+
+// CollectContext collects the Metrics of the wrapped Collector, passing the
+// provided Context if it is a CollectorContext.
+func (wc *wrappingCollector) CollectContext(ctx context.Context, ch chan<- Metric) {
+	metrics := make(chan Metric)
+	var recovered interface{}
+	go func() {
+		defer close(metrics)
+		defer func() {
+			// Pass panics on to the caller, which isolates them.
+			recovered = recover()
+		}()
+		if cc, ok := wc.collector.(CollectorContext); ok {
+			cc.CollectContext(ctx, metrics)
+		} else {
+			wc.collector.Collect(metrics)
+		}
+	}()
+	for m := range metrics {
+		ch <- &wrappingMetric{
+			metric:  m,
+			wrapper: wc,
+		}
+	}
+	if recovered != nil {
+		panic(recovered)
+	}
+}
+
+// Describe sends the descriptions of the wrapped Collector with prefixed
+// names.
+func (wc *wrappingCollector) Describe(ch chan<- *Desc) {
+	d, ok := wc.collector.(Describer)
+	if !ok {
+		return
+	}
+	for _, desc := range describe(d) {
+		prefixed := *desc
+		prefixed.Name = wc.prefix + desc.Name
+		ch <- &prefixed
+	}
+}
+
+func (wc *wrappingCollector) String() string {
+	if stringer, ok := wc.collector.(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return fmt.Sprintf("%T", wc.collector)
+}
+
+type wrappingMetric struct {
+	metric  Metric
+	wrapper *wrappingCollector
+}
+
+func (wm *wrappingMetric) Write(md *MetricData) error {
+	if err := wm.metric.Write(md); err != nil {
+		return err
+	}
+	if md.Name == "" {
+		return nil
+	}
+
+	md.Name = wm.wrapper.prefix + md.Name
+	if len(wm.wrapper.fields) > 0 {
+		// NOTE: Metrics might share their fields, never modify them.
+		fields := make(map[string]interface{}, len(md.Fields)+len(wm.wrapper.fields))
+		for key, value := range md.Fields {
+			fields[key] = value
+		}
+		for key, value := range wm.wrapper.fields {
+			if _, exists := fields[key]; exists {
+				return fmt.Errorf("metric %q: constant field %q already set", md.Name, key)
+			}
+			fields[key] = value
+		}
+		md.Fields = fields
+	}
+
+	return nil
+}
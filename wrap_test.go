@@ -0,0 +1,93 @@
+/*
+ * Copyright 2019 Kopano and its licensors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ksurveyclient
+
+import (
+	"testing"
+)
+
+func TestWrapRegistryWithPrefix(t *testing.T) {
+	reg := NewRegistry()
+	wrapper := WrapRegistryWithPrefix("kopano_", reg)
+	program := NewProgramCollector("kopano-test", "1.2.3", testGUID)
+	wrapper.MustRegister(program)
+	if err := reg.Register(NewProgramCollector("other", "3.2.1", testGUID)); err != nil {
+		t.Errorf("failed to register unwrapped collector with same names: %v", err)
+	}
+	if err := wrapper.Register(program); err == nil {
+		t.Error("expected error when registering the same collector twice")
+	}
+
+	ms, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+	if md := ms.Get("kopano_program_name"); md == nil || md.Value() != "kopano-test" {
+		t.Errorf("unexpected prefixed metric: %v", md)
+	}
+	if md := ms.Get("program_name"); md == nil || md.Value() != "other" {
+		t.Errorf("unexpected unprefixed metric: %v", md)
+	}
+
+	if !wrapper.Unregister(program) {
+		t.Error("failed to unregister wrapped collector")
+	}
+	if wrapper.Unregister(program) {
+		t.Error("unregistered collector which is not registered")
+	}
+	ms, _ = reg.Gather()
+	if ms.Get("kopano_program_name") != nil {
+		t.Error("unregistered collector was gathered")
+	}
+
+	if err := WrapRegistryWithPrefix("0_", reg).Register(program); err == nil {
+		t.Error("expected error for invalid prefix")
+	}
+}
+
+func TestWrapRegistryWithFields(t *testing.T) {
+	reg := NewRegistry()
+	wrapper := WrapRegistryWithPrefix("kopano_", WrapRegistryWithFields(map[string]interface{}{
+		"product": "groupware",
+	}, reg))
+	usercnt := MustNewConstInt(testUsercntDesc, 42)
+	wrapper.MustRegister(usercnt, &panickingCollector{})
+
+	ms, err := reg.Gather()
+	if err == nil {
+		t.Error("expected error from panicking collector")
+	}
+	md := ms.Get("kopano_usercnt_active")
+	if md == nil || md.Value() != int64(42) || md.Fields["product"] != "groupware" {
+		t.Fatalf("unexpected wrapped metric: %v", md)
+	}
+	if _, ok := usercnt.(*constMap).fields["product"]; ok {
+		t.Error("fields of the wrapped metric were modified")
+	}
+	if err := Validate(&Payload{Version: PayloadV2, Stats: ms}); err != nil {
+		t.Errorf("invalid payload: %v", err)
+	}
+
+	for _, fields := range []map[string]interface{}{
+		{"value": 1},
+		{"invalid": make(chan int)},
+	} {
+		if err := WrapRegistryWithFields(fields, reg).Register(NewProgramCollector("", "", nil)); err == nil {
+			t.Errorf("expected error for fields %v", fields)
+		}
+	}
+}